@@ -0,0 +1,132 @@
+package sqlol
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 参数标记，生成sql时替换为字面值(Build)或占位符(BuildArgs)，
+// tip: String会去掉字符串中的\000，字面值中不会出现该标记
+const argMarker = "\x00"
+
+type Prepared struct {
+	SQL  string
+	Args []interface{}
+	// 与Args一一对应的参数名，方言为NamedDialect时填充，位置参数的方言为空
+	Names []string
+}
+
+// 生成参数化的sql，值使用$1、$2...占位，并按占位顺序返回参数
+func (b *Builder) BuildArgs() (string, []interface{}) {
+	sql, args, err := b.buildArgs()
+	if err != nil {
+		b.handleErr(err)
+		return "", nil
+	}
+	return sql, args
+}
+
+func (b *Builder) buildArgs() (sql string, args []interface{}, err error) {
+	defer recoverBuildError(&err)
+	sql, args = b.render((*Builder).build)
+	sql, args = bindArgs(sql, args, b.dialect(), true)
+	return sql, args, nil
+}
+
+// 生成参数化sql及参数，BuildArgs的便捷封装
+func (b *Builder) Prepare() Prepared {
+	sql, args := b.BuildArgs()
+	p := Prepared{SQL: sql, Args: args}
+	if d, ok := b.dialect().(NamedDialect); ok && len(args) > 0 {
+		p.Names = make([]string, len(args))
+		for i := range args {
+			p.Names[i] = d.ParamName(i + 1)
+		}
+	}
+	return p
+}
+
+// 生成带参数标记的sql，同时收集参数，标识符标记在此按QuoteIdentifiers替换；
+// build在b的浅拷贝上执行，参数只收集到拷贝中，多个goroutine可同时生成同一个Builder
+func (b *Builder) render(build func(*Builder) string) (string, []interface{}) {
+	r := *b
+	r.args = nil
+	sql := build(&r)
+	return r.ConditionBuilder.resolveIdents(sql), r.args
+}
+
+// 将参数标记替换为按方言d转义的字面值，d为nil时按Postgres
+func inlineArgs(sql string, args []interface{}, d Dialect) string {
+	sql, _ = bindArgs(sql, args, d, false)
+	return sql
+}
+
+// 将参数标记依次替换为方言d的占位符，bind为false时替换为字面值
+func bindArgs(sql string, args []interface{}, d Dialect, bind bool) (string, []interface{}) {
+	if strings.Count(sql, argMarker) != len(args) {
+		log.Panic("sqlol: arguments count mismatch")
+	}
+	if len(args) == 0 {
+		return sql, nil
+	}
+	if d == nil {
+		d = Postgres
+	}
+	var bound []interface{}
+	var buf strings.Builder
+	for _, arg := range args {
+		i := strings.Index(sql, argMarker)
+		buf.WriteString(sql[:i])
+		sql = sql[i+len(argMarker):]
+		if _, ok := arg.(Interval); ok || !bind {
+			buf.WriteString(literal(arg, d.QuoteString))
+		} else {
+			bound = append(bound, argValue(arg))
+			buf.WriteString(d.Placeholder(len(bound)))
+		}
+	}
+	buf.WriteString(sql)
+	return buf.String(), bound
+}
+
+// 转换为驱动可接受的参数值，struct、map、切片等(与ToString一致)转换为json字符串
+func argValue(arg interface{}) interface{} {
+	switch arg.(type) {
+	case nil, []byte, time.Time:
+		return arg
+	}
+	if str, ok := numericString(arg); ok {
+		return str
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return arg
+	}
+	v := reflect.ValueOf(arg)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return argValue(v.Elem().Interface())
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		b, err := json.Marshal(arg)
+		if err != nil {
+			log.Panic("sqlol json.Marshal: ", err)
+		}
+		return string(b)
+	}
+	return arg
+}
+
+// 生成n个以逗号分隔的参数标记
+func argList(n int) string {
+	markers := make([]string, n)
+	for i := range markers {
+		markers[i] = argMarker
+	}
+	return strings.Join(markers, ",")
+}
@@ -0,0 +1,229 @@
+package sqlol
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestBuilder_BuildArgs(t *testing.T) {
+	b := NewBuilder().Select("a.user").
+		Equal("name", "a").
+		In("id", []int{1, 2}).
+		Between("age", 18, 30).
+		Limit(10).Offset(20)
+	sql, args := b.BuildArgs()
+	want := "SELECT * FROM a.user WHERE (name = $1) AND (id IN ($2,$3)) AND (age BETWEEN $4 AND $5) " +
+		"LIMIT $6 OFFSET $7"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs := []interface{}{"a", 1, 2, 18, 30, int64(10), int64(20)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+
+	// Build仍然生成字面值
+	want = "SELECT * FROM a.user WHERE (name = 'a') AND (id IN (1,2)) AND (age BETWEEN 18 AND 30) " +
+		"LIMIT 10 OFFSET 20"
+	if got := trimSQL(b.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_BuildArgsLike(t *testing.T) {
+	sql, args := NewBuilder().Select("a.user").
+		Like("name", "foo").
+		TryLike("remark", " ").
+		MultiLike([]string{"phone", "email"}, "bar").
+		BuildArgs()
+	want := "SELECT * FROM a.user WHERE (name LIKE $1) AND ((phone LIKE $2) OR (email LIKE $3))"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs := []interface{}{"%foo%", "%bar%", "%bar%"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBuilder_BuildArgsInterval(t *testing.T) {
+	b := NewBuilder().Select("a.task").
+		Between("duration", Interval("1 hour"), Interval("2 hours")).
+		Equal("retry_after", Interval("5 minutes")).
+		Equal("name", "a")
+	want := "SELECT * FROM a.task WHERE (duration BETWEEN INTERVAL '1 hour' AND INTERVAL '2 hours') " +
+		"AND (retry_after = INTERVAL '5 minutes') AND (name = 'a')"
+	if got := trimSQL(b.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql, args := b.BuildArgs()
+	want = "SELECT * FROM a.task WHERE (duration BETWEEN INTERVAL '1 hour' AND INTERVAL '2 hours') " +
+		"AND (retry_after = INTERVAL '5 minutes') AND (name = $1)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a"}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+}
+
+func TestBuilder_BuildArgsTyped(t *testing.T) {
+	sql, args := NewBuilder().Select("a.user").
+		EqualTyped("uuid", "uuid", "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11").
+		EqualTyped("deleted_at", "timestamptz", nil).
+		BuildArgs()
+	want := "SELECT * FROM a.user WHERE (uuid = $1::uuid) AND (deleted_at IS NULL)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+}
+
+func TestBuilder_BuildArgsValues(t *testing.T) {
+	type user struct {
+		Name string
+		Tags []string
+	}
+	sql, args := NewBuilder().Insert("a.user").Cols("Name", "Tags").
+		Values([]user{{"a", []string{"x"}}, {"b", nil}}).
+		BuildArgs()
+	want := "INSERT INTO a.user(name,tags) VALUES ($1,$2),($3,$4)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs := []interface{}{"a", `["x"]`, "b", "null"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+
+	// SET、WHERE、LIMIT的占位符连续编号
+	sql, args = NewBuilder().Update("a.user").
+		Set("age = age + 1").
+		SetMap(map[string]interface{}{"name": "c"}).
+		Cols("Remark").SetStructAsAssignments(User{Remark: "r"}).
+		Equal("id", 1).
+		Limit(1).
+		BuildArgs()
+	want = "UPDATE a.user SET age = age + 1,name = $1,remark = $2 WHERE (id = $3) LIMIT $4"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs = []interface{}{"c", "r", 1, int64(1)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+
+	sql, args = NewBuilder().Update("a.user").
+		Cols("Name", "Remark").SetStruct(User{Name: "d", Remark: "e"}).
+		Equal("id", 2).
+		BuildArgs()
+	want = "UPDATE a.user SET (name,remark) = ($1,$2) WHERE (id = $3)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs = []interface{}{"d", "e", 2}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBuilder_Prepare(t *testing.T) {
+	p := NewBuilder().Select("a.user").
+		Equal("name", "a").
+		Equal("remark", nil).
+		Limit(10).
+		Prepare()
+	want := "SELECT * FROM a.user WHERE (name = $1) AND (remark IS NULL) LIMIT $2"
+	if got := trimSQL(p.SQL); got != want {
+		t.Errorf("Prepare() SQL = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(p.Args, []interface{}{"a", int64(10)}) {
+		t.Errorf("Prepare() Args = %v", p.Args)
+	}
+	if p.Names != nil {
+		t.Errorf("Prepare() Names = %v, want nil", p.Names)
+	}
+
+	p = NewBuilder().Dialect(namedDialect{}).Select("a.user").
+		Equal("name", "a").
+		Limit(10).
+		Prepare()
+	want = "SELECT * FROM a.user WHERE (name = :p1) LIMIT :p2"
+	if got := trimSQL(p.SQL); got != want {
+		t.Errorf("Prepare() SQL = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(p.Args, []interface{}{"a", int64(10)}) {
+		t.Errorf("Prepare() Args = %v", p.Args)
+	}
+	if !reflect.DeepEqual(p.Names, []string{"p1", "p2"}) {
+		t.Errorf("Prepare() Names = %v", p.Names)
+	}
+}
+
+// 以 :p1、:p2... 作占位符的命名参数方言
+type namedDialect struct {
+	postgres
+}
+
+func (namedDialect) Placeholder(n int) string {
+	return ":p" + strconv.Itoa(n)
+}
+
+func (namedDialect) ParamName(n int) string {
+	return "p" + strconv.Itoa(n)
+}
+
+func TestBuilder_BuildArgsConcurrent(t *testing.T) {
+	builder := NewBuilder().Select("a.user").
+		Equal("name", "a").
+		With("vip", NewBuilder().Select("a.vip").Fields("user_id").Equal("level", 3)).
+		Limit(10)
+	want := trimSQL(builder.Clone().Build())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, args := builder.BuildArgs(); len(args) != 3 {
+				t.Errorf("BuildArgs() args = %v", args)
+			}
+			if got := trimSQL(builder.Build()); got != want {
+				t.Errorf("Build() = %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBuilder_BuildArgsWith(t *testing.T) {
+	sql, args := NewBuilder().
+		With("u", NewBuilder().Select("a.user").Equal("age", 18)).
+		Select("u").
+		Equal("name", "a").
+		BuildArgs()
+	want := "WITH u AS (SELECT * FROM a.user WHERE (age = $1)) SELECT * FROM u WHERE (name = $2)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{18, "a"}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+}
+
+func TestBuilder_BuildArgsArray(t *testing.T) {
+	sql, args := NewBuilder().Select("a.user").
+		Equal("tags", AsArray([]string{"a", "b,c"})).
+		BuildArgs()
+	if got, want := trimSQL(sql), "SELECT * FROM a.user WHERE (tags = $1)"; got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	value, err := args[0].(driver.Valuer).Value()
+	if err != nil || value != `{"a","b,c"}` {
+		t.Errorf("BuildArgs() arg = %v, %v", value, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,21 +13,65 @@ type Builder struct {
 	manipulation     string
 	table            string
 	tableAlias       string
-	join             []string
+	join             []joinClause
 	groupBy          []string
-	orderBy          []string
-	having           string
+	orderBy          []orderItem
 	limit            int64
+	limitPercent     float64
 	offset           int64
 	isForUpdate      bool
-	fields           []string
+	isForShare       bool
+	lockWait         string
+	fields           []selectField
+	distinct         bool
+	distinctOn       []string
 	cols             []string
 	returning        []string
 	onConflict       string
+	conflictUpdate   *conflictUpdate
 	values           interface{}
 	updates          []string
+	updateArgs       []interface{}
 	updateStruct     interface{}
+	with             []cte
+	omitAs           bool
+	nullsOrder       string
+	statementTimeout time.Duration
+	debug            bool
+	correlate        string
+	schema           string
+	readOnly         bool
+	allowEmptyInsert bool
+	primaryKey       string
+	err              error
 	ConditionBuilder ConditionBuilder
+	// HAVING条件，与WHERE条件一样通过ConditionBuilder构建
+	HavingBuilder ConditionBuilder
+	// 生成sql过程中收集的参数，只在render的拷贝上使用
+	args []interface{}
+}
+
+type selectField struct {
+	expr  string
+	alias string
+}
+
+type joinClause struct {
+	joinType string
+	table    string
+	as       string
+	on       string
+}
+
+type conflictUpdate struct {
+	fields   []string
+	cols     []string
+	preserve []string
+}
+
+type cte struct {
+	name  string
+	query *Builder
 }
 
 func NewBuilder() *Builder {
@@ -35,26 +80,42 @@ func NewBuilder() *Builder {
 
 func (b *Builder) Clone() *Builder {
 	return &Builder{
-		manipulation: b.manipulation,
-		table:        b.table,
-		tableAlias:   b.tableAlias,
-		join:         copyStringSlice(b.join),
-		groupBy:      copyStringSlice(b.groupBy),
-		orderBy:      copyStringSlice(b.orderBy),
-		having:       b.having,
-		limit:        b.limit,
-		offset:       b.offset,
-		isForUpdate:  b.isForUpdate,
-		fields:       copyStringSlice(b.fields),
-		cols:         copyStringSlice(b.cols),
-		returning:    copyStringSlice(b.returning),
-		onConflict:   b.onConflict,
-		values:       b.values,
-		updates:      copyStringSlice(b.updates),
-		updateStruct: b.updateStruct,
-		ConditionBuilder: ConditionBuilder{
-			wheres: copyStringSlice(b.ConditionBuilder.wheres),
-		},
+		manipulation:     b.manipulation,
+		table:            b.table,
+		tableAlias:       b.tableAlias,
+		join:             append([]joinClause(nil), b.join...),
+		groupBy:          copyStringSlice(b.groupBy),
+		orderBy:          append([]orderItem(nil), b.orderBy...),
+		limit:            b.limit,
+		limitPercent:     b.limitPercent,
+		offset:           b.offset,
+		isForUpdate:      b.isForUpdate,
+		isForShare:       b.isForShare,
+		lockWait:         b.lockWait,
+		fields:           append([]selectField(nil), b.fields...),
+		distinct:         b.distinct,
+		distinctOn:       copyStringSlice(b.distinctOn),
+		cols:             copyStringSlice(b.cols),
+		returning:        copyStringSlice(b.returning),
+		onConflict:       b.onConflict,
+		conflictUpdate:   b.conflictUpdate,
+		values:           b.values,
+		updates:          copyStringSlice(b.updates),
+		updateArgs:       append([]interface{}(nil), b.updateArgs...),
+		updateStruct:     b.updateStruct,
+		with:             append([]cte(nil), b.with...),
+		omitAs:           b.omitAs,
+		nullsOrder:       b.nullsOrder,
+		statementTimeout: b.statementTimeout,
+		debug:            b.debug,
+		correlate:        b.correlate,
+		schema:           b.schema,
+		readOnly:         b.readOnly,
+		allowEmptyInsert: b.allowEmptyInsert,
+		primaryKey:       b.primaryKey,
+		err:              b.err,
+		ConditionBuilder: b.ConditionBuilder.clone(),
+		HavingBuilder:    b.HavingBuilder.clone(),
 	}
 }
 
@@ -65,18 +126,36 @@ func (b *Builder) Clear() {
 	b.join = nil
 	b.groupBy = nil
 	b.orderBy = nil
-	b.having = ""
 	b.limit = 0
+	b.limitPercent = 0
 	b.offset = 0
 	b.isForUpdate = false
+	b.isForShare = false
+	b.lockWait = ""
 	b.fields = nil
+	b.distinct = false
+	b.distinctOn = nil
 	b.cols = nil
 	b.returning = nil
 	b.onConflict = ""
+	b.conflictUpdate = nil
 	b.values = nil
 	b.updates = nil
+	b.updateArgs = nil
 	b.updateStruct = nil
+	b.with = nil
+	b.omitAs = false
+	b.nullsOrder = ""
+	b.statementTimeout = 0
+	b.debug = false
+	b.correlate = ""
+	b.schema = ""
+	b.readOnly = false
+	b.allowEmptyInsert = false
+	b.primaryKey = ""
+	b.err = nil
 	b.ConditionBuilder.Clear()
+	b.HavingBuilder.Clear()
 }
 
 func (b *Builder) Insert(table string) *Builder {
@@ -108,18 +187,227 @@ func (b *Builder) SelectSubQuery(subQuery string) *Builder {
 	return b
 }
 
+// 查询返回集合的函数，如 SELECT * FROM generate_series(1,10) AS g，
+// 参数通过ToString转换
+func (b *Builder) SelectFunction(fn string, alias string, args ...interface{}) *Builder {
+	b.manipulation = manipulationSelect
+	b.table = fn + "(" + sliceValue(args) + ")"
+	b.tableAlias = alias
+	return b
+}
+
+// 只读模式，Build时若为INSERT、UPDATE、DELETE(包括WITH中的修改语句)则报错，
+// 用于防止只读的代码路径中意外写入
+func (b *Builder) ReadOnly() *Builder {
+	b.readOnly = true
+	return b
+}
+
+func (b *Builder) isReadOnly() bool {
+	if b.manipulation != manipulationSelect {
+		return false
+	}
+	for _, c := range b.with {
+		if !c.query.isReadOnly() {
+			return false
+		}
+	}
+	return true
+}
+
+// 设置默认schema，未指定schema的表名(包括JOIN的表)会加上该前缀，
+// 已带schema的表名、子查询、函数及WITH中定义的名称不受影响
+func (b *Builder) Schema(schema string) *Builder {
+	b.schema = schema
+	return b
+}
+
+// 为裸表名加上默认schema前缀
+func (b *Builder) qualify(table string) string {
+	if b.schema == "" || strings.Contains(table, ".") || !isBareIdentifier(table) {
+		return table
+	}
+	for _, c := range b.with {
+		if c.name == table {
+			return table
+		}
+	}
+	return b.schema + "." + table
+}
+
+// 声明为引用外层查询outerAlias的关联子查询，Build时检查WHERE中是否引用了outerAlias
+func (b *Builder) Correlate(outerAlias string) *Builder {
+	b.correlate = outerAlias
+	return b
+}
+
+// 添加CTE(WITH子句)，query可以是SELECT，也可以是INSERT/UPDATE/DELETE，
+// 数据修改类的CTE需要配合Returning使用
+func (b *Builder) With(name string, query *Builder) *Builder {
+	b.with = append(b.with, cte{name: name, query: query})
+	return b
+}
+
 func (b *Builder) Alias(alias string) *Builder {
 	b.tableAlias = alias
 	return b
 }
 
+// 别名前省略AS关键字，作用于表、JOIN以及FieldAs等添加的字段别名
+func (b *Builder) OmitAs() *Builder {
+	b.omitAs = true
+	return b
+}
+
+func (b *Builder) aliasExpr(expr, alias string) string {
+	if alias == "" {
+		return expr
+	}
+	if b.omitAs {
+		return expr + " " + alias
+	}
+	return expr + " AS " + alias
+}
+
 func (b *Builder) OrderBy(order ...string) *Builder {
-	b.orderBy = append(b.orderBy, order...)
+	for _, o := range order {
+		b.orderBy = append(b.orderBy, parseOrders(o)...)
+	}
+	return b
+}
+
+// 反转所有排序项的方向，ASC与DESC互换、NULLS FIRST与NULLS LAST互换，
+// 未指定方向的视为ASC，用于游标分页的上一页查询
+func (b *Builder) ReverseOrder() *Builder {
+	for i, order := range b.orderBy {
+		b.orderBy[i] = order.reverse()
+	}
+	switch b.nullsOrder {
+	case "NULLS FIRST":
+		b.nullsOrder = "NULLS LAST"
+	case "NULLS LAST":
+		b.nullsOrder = "NULLS FIRST"
+	}
+	return b
+}
+
+// 按fields升序排序，如 ORDER BY a ASC,b ASC
+func (b *Builder) OrderByAsc(fields ...string) *Builder {
+	for _, field := range fields {
+		b.orderBy = append(b.orderBy, orderItem{expr: field, dir: "ASC"})
+	}
+	return b
+}
+
+// 按fields降序排序，如 ORDER BY a DESC,b DESC
+func (b *Builder) OrderByDesc(fields ...string) *Builder {
+	for _, field := range fields {
+		b.orderBy = append(b.orderBy, orderItem{expr: field, dir: "DESC"})
+	}
+	return b
+}
+
+// 按field排序并将NULL排在最后，如 ORDER BY a DESC NULLS LAST，dir为ASC或DESC(不区分大小写)
+func (b *Builder) OrderByNullsLast(field, dir string) *Builder {
+	dir = strings.ToUpper(strings.TrimSpace(dir))
+	if dir != "ASC" && dir != "DESC" {
+		log.Panicf("sqlol: order direction must be ASC or DESC, got %q", dir)
+	}
+	b.orderBy = append(b.orderBy, orderItem{expr: field, dir: dir, nulls: "NULLS LAST"})
+	return b
+}
+
+// 按values中的顺序排序，通常与In配合使用，保持结果与传入的id顺序一致，
+// 如 ORDER BY array_position(ARRAY[3,1,2], id)，values与In的值一样处理，BuildArgs时作为参数绑定
+func (b *Builder) OrderByInOrder(dbField string, values interface{}) *Builder {
+	if args := sliceArgs(values); len(args) > 0 {
+		b.orderBy = append(b.orderBy, orderItem{
+			expr: fmt.Sprintf("array_position(ARRAY[%s], %s)", argList(len(args)), markIdent(dbField)),
+			args: args,
+		})
+	}
+	return b
+}
+
+// 按COALESCE(expr, fallback)排序，expr为NULL时按fallback排序，如LEFT JOIN的字段，
+// fallback与条件的值一样处理，BuildArgs时作为参数绑定
+func (b *Builder) OrderByCoalesce(expr string, fallback interface{}, desc bool) *Builder {
+	order := orderItem{expr: fmt.Sprintf("COALESCE(%s, %s)", markIdent(expr), argMarker), args: []interface{}{fallback}}
+	if desc {
+		order.dir = "DESC"
+	}
+	b.orderBy = append(b.orderBy, order)
+	return b
+}
+
+// 按select字段的位置排序，如 ORDER BY 1,2，已指定Fields时校验位置不超过字段数
+func (b *Builder) OrderByPosition(positions ...int) *Builder {
+	return b.orderByPosition("", positions)
+}
+
+// 按select字段的位置倒序排序，如 ORDER BY 1 DESC,2 DESC
+func (b *Builder) OrderByPositionDesc(positions ...int) *Builder {
+	return b.orderByPosition("DESC", positions)
+}
+
+func (b *Builder) orderByPosition(direction string, positions []int) *Builder {
+	count := b.fieldCount()
+	for _, position := range positions {
+		if position < 1 || (count > 0 && position > count) {
+			log.Panicf("sqlol: order by position %d out of range of %d fields", position, count)
+			return b
+		}
+		b.orderBy = append(b.orderBy, orderItem{expr: strconv.Itoa(position), dir: direction})
+	}
+	return b
+}
+
+// 返回select字段数，未指定Fields时返回0；括号内的逗号不作为字段分隔
+func (b *Builder) fieldCount() int {
+	count := 0
+	for _, field := range b.fields {
+		count++
+		depth := 0
+		for _, r := range field.expr {
+			switch r {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth == 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// 为所有未指定NULLS FIRST/LAST的排序字段统一设置NULL值的排序位置
+func (b *Builder) NullsOrder(last bool) *Builder {
+	if last {
+		b.nullsOrder = "NULLS LAST"
+	} else {
+		b.nullsOrder = "NULLS FIRST"
+	}
 	return b
 }
 
 func (b *Builder) Limit(limit int64) *Builder {
 	b.limit = limit
+	b.limitPercent = 0
+	return b
+}
+
+// 按百分比限制返回行数，子句由方言生成，如 FETCH FIRST 10 PERCENT ROWS ONLY，与Limit互斥，后调用的生效，
+// percent需在(0,100]之间；tip: 方言需实现PercentDialect，Postgres、MySQL不支持，生成时报错
+func (b *Builder) LimitPercent(percent float64) *Builder {
+	if percent <= 0 || percent > 100 {
+		log.Panicf("sqlol: limit percent must be in (0, 100], got %v", percent)
+	}
+	b.limitPercent = percent
+	b.limit = 0
 	return b
 }
 
@@ -128,6 +416,26 @@ func (b *Builder) Offset(offset int64) *Builder {
 	return b
 }
 
+// 按页码分页，page从1开始，小于1时按1处理，size不大于0时忽略
+func (b *Builder) Paginate(page, size int64) *Builder {
+	if size <= 0 {
+		return b
+	}
+	if page < 1 {
+		page = 1
+	}
+	b.offset = (page - 1) * size
+	return b.Limit(size)
+}
+
+// 可选分页，size为0时不分页，page小于1时按第1页
+func (b *Builder) TryPaginate(page, size int64) *Builder {
+	if size == 0 {
+		return b
+	}
+	return b.Paginate(page, size)
+}
+
 // 添加自定义sql策略 Strategy接口形式
 func (b *Builder) Strategies(strategies ...Strategy) *Builder {
 	for _, strategy := range strategies {
@@ -151,49 +459,157 @@ func (b *Builder) StrategyFuncs(strategyFuncs ...StrategyFunc) *Builder {
 }
 
 func (b *Builder) Build() string {
-	if b.table == "" {
-		log.Panic("sqlol: table is required")
+	sql, err := b.BuildE()
+	if err != nil {
+		b.handleErr(err)
+	}
+	return sql
+}
+
+// 生成sql，出错时返回错误而不是panic，如缺少表名时返回的错误满足 errors.Is(err, ErrNoTable)
+func (b *Builder) BuildE() (sql string, err error) {
+	defer recoverBuildError(&err)
+	sql, args := b.render((*Builder).build)
+	return inlineArgs(sql, args, b.dialect()), nil
+}
+
+func (b *Builder) build() string {
+	if errs := b.structuralErrors(); len(errs) > 0 {
+		b.fail(errs[0])
+		return ""
+	}
+	if b.readOnly && !b.isReadOnly() {
+		b.fail(ErrReadOnly)
 		return ""
 	}
+	// tip: WITH在最前面，需要先生成，保证参数顺序
+	with := b.buildWith()
+	var sql string
 	switch b.manipulation {
 	case manipulationSelect:
-		return b.query()
+		sql = b.query()
 	case manipulationInsert:
-		return b.insert()
+		sql = b.insert()
 	case manipulationUpdate:
-		return b.update()
+		sql = b.update()
 	case manipulationDelete:
-		return b.delete()
+		sql = b.delete()
 	default:
 		log.Panic("sqlol: wrong manipulation")
 		return ""
 	}
+	if sql == "" {
+		// tip: AllowEmptyInsert时插入语句为空，WITH及其参数也一并丢弃
+		b.args = nil
+		return ""
+	}
+	if with != "" {
+		sql = with + " " + sql
+	}
+	return sql
+}
+
+// 调试模式，在生成的sql各子句前添加注释，如 /* where */，便于定位子句来源，
+// 仅用于开发调试
+func (b *Builder) Debug() *Builder {
+	b.debug = true
+	return b
+}
+
+func (b *Builder) annotate(clause, sql string) string {
+	if !b.debug || sql == "" {
+		return sql
+	}
+	return "/* " + clause + " */ " + sql
+}
+
+// 设置语句执行超时时间，通过BuildStatements在语句前生成SET LOCAL statement_timeout，
+// SET LOCAL只在当前事务内生效，需要在事务中执行
+func (b *Builder) StatementTimeout(d time.Duration) *Builder {
+	b.statementTimeout = d
+	return b
+}
+
+// 生成设置超时的语句，未设置超时时返回空字符串
+func (b *Builder) BuildStatementTimeout() string {
+	if b.statementTimeout <= 0 {
+		return ""
+	}
+	ms := b.statementTimeout.Milliseconds()
+	if ms == 0 {
+		ms = 1
+	}
+	return fmt.Sprintf("SET LOCAL statement_timeout = %d", ms)
+}
+
+// 生成需要依次执行的语句，设置了超时时第一条为SET LOCAL statement_timeout
+func (b *Builder) BuildStatements() []string {
+	var statements []string
+	if timeout := b.BuildStatementTimeout(); timeout != "" {
+		statements = append(statements, timeout)
+	}
+	sql := b.Build()
+	if sql == "" {
+		return nil
+	}
+	return append(statements, sql)
 }
 
 func (b *Builder) BuildCount() string {
+	sql, err := b.BuildCountE()
+	if err != nil {
+		b.handleErr(err)
+	}
+	return sql
+}
+
+// 生成计数sql，出错时返回错误而不是panic
+func (b *Builder) BuildCountE() (sql string, err error) {
+	defer recoverBuildError(&err)
+	sql, args := b.render((*Builder).buildCount)
+	return inlineArgs(sql, args, b.dialect()), nil
+}
+
+func (b *Builder) buildCount() string {
 	if b.table == "" {
-		log.Panic("sqlol: table is required")
+		b.fail(ErrNoTable)
 		return ""
 	}
 	if b.manipulation != manipulationSelect {
-		log.Panic("sqlol: must be a select operation")
+		b.fail(ErrNotSelect)
 		return ""
 	}
-	if len(b.groupBy) == 0 {
+	with := b.buildWith()
+	sql := b.buildCountQuery()
+	if with != "" {
+		sql = with + " " + sql
+	}
+	return sql
+}
+
+func (b *Builder) buildCountQuery() string {
+	distinct := b.distinct || len(b.distinctOn) > 0
+	if len(b.groupBy) == 0 && !distinct {
+		count := "COUNT(1) FROM"
+		if b.primaryKey != "" && len(b.join) > 0 {
+			// tip: JOIN可能使主表的行重复，按主键去重计数
+			count = fmt.Sprintf("COUNT(DISTINCT %s) FROM", b.primaryKeyExpr())
+		}
 		return strings.Join([]string{
 			b.manipulation,
-			"COUNT(1) FROM",
+			count,
 			b.tableName(),
 			b.buildJoin(),
 			b.buildWhere(),
 		}, " ")
 	}
 	if len(b.groupBy) == 1 &&
-		b.having == "" &&
+		!distinct &&
+		len(b.HavingBuilder.wheres) == 0 &&
 		!strings.Contains(b.groupBy[0], ",") {
 		return strings.Join([]string{
 			b.manipulation,
-			fmt.Sprintf("COUNT(DISTINCT %s) FROM", b.groupBy[0]),
+			fmt.Sprintf("COUNT(DISTINCT %s) FROM", b.quote(b.groupBy[0])),
 			b.tableName(),
 			b.buildJoin(),
 			b.buildWhere(),
@@ -208,41 +624,149 @@ func (b *Builder) BuildCount() string {
 		b.buildGroup(),
 		b.buildHaving(),
 	}, " ")
-	return fmt.Sprintf(`SELECT count(1) FROM (%s) AS sqlolcount`, subSql)
+	return fmt.Sprintf(`SELECT count(1) FROM %s`, b.aliasExpr("("+subSql+")", "sqlolcount"))
+}
+
+// 生成基于pg_class.reltuples的估算行数sql，用于超大表分页时代替COUNT(1)，
+// 结果是最近一次VACUUM/ANALYZE时的统计值，并非精确值(从未ANALYZE的表可能为-1)，
+// 只适用于不带WHERE和JOIN的全表计数
+func (b *Builder) BuildCountEstimate() string {
+	sql, err := b.BuildCountEstimateE()
+	if err != nil {
+		b.handleErr(err)
+	}
+	return sql
+}
+
+// 生成估算行数sql，出错时返回错误而不是panic，如带WHERE时满足 errors.Is(err, ErrEstimateFiltered)
+func (b *Builder) BuildCountEstimateE() (sql string, err error) {
+	defer recoverBuildError(&err)
+	if b.table == "" {
+		b.fail(ErrNoTable)
+	}
+	if b.manipulation != manipulationSelect {
+		b.fail(ErrNotSelect)
+	}
+	if strings.HasPrefix(b.table, "(") {
+		b.fail(ErrEstimateSubQuery)
+	}
+	if len(b.ConditionBuilder.wheres) > 0 || len(b.join) > 0 || len(b.groupBy) > 0 {
+		b.fail(ErrEstimateFiltered)
+	}
+	return fmt.Sprintf("SELECT reltuples::bigint AS estimate FROM pg_class WHERE oid = %s::regclass",
+		String(b.qualify(b.table))), nil
+}
+
+func (b *Builder) buildWith() string {
+	if len(b.with) == 0 {
+		return ""
+	}
+	var ctes []string
+	for _, c := range b.with {
+		sql, args := c.query.render((*Builder).build)
+		b.args = append(b.args, args...)
+		ctes = append(ctes, fmt.Sprintf("%s AS (%s)", b.quote(c.name), strings.TrimSpace(sql)))
+	}
+	return b.annotate("with", "WITH "+strings.Join(ctes, ","))
 }
 
+// 去掉标识符的引号，开启QuoteIdentifiers时 "u"."id" 与 u.id 按相同的标识符比较
+var identQuoteStripper = strings.NewReplacer(`"`, "", "`", "")
+
 func (b *Builder) buildWhere() string {
-	condition := b.ConditionBuilder.Build()
+	condition, args := b.ConditionBuilder.build()
+	condition = b.ConditionBuilder.resolveIdents(condition)
+	b.args = append(b.args, args...)
+	if b.correlate != "" &&
+		!strings.Contains(identQuoteStripper.Replace(condition), identQuoteStripper.Replace(b.correlate)+".") {
+		log.Panicf("sqlol: correlated sub query must reference outer alias %s", b.correlate)
+	}
 	if condition != "" {
-		condition = "WHERE " + condition
+		condition = b.annotate("where", "WHERE "+condition)
 	}
 	return condition
 }
 
 func (b *Builder) tableName() string {
-	table := b.table
-	if b.tableAlias != "" {
-		table += " AS " + b.tableAlias
+	return b.aliasExpr(b.quote(b.qualify(b.table)), b.quote(b.tableAlias))
+}
+
+// 开启QuoteIdentifiers时转义裸标识符，用于表名、别名、字段等
+func (b *Builder) quote(ident string) string {
+	if !b.ConditionBuilder.quoteIdentifiers || ident == "" {
+		return ident
+	}
+	return quoteIdentifier(ident, b.ConditionBuilder.dialect)
+}
+
+func (b *Builder) quoteAll(idents []string) []string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = b.quote(ident)
+	}
+	return quoted
+}
+
+// 逗号分隔的字段列表，字段名转为snake形式
+func (b *Builder) columnList(cols []string) string {
+	cols = CamelsToSnakes(cols)
+	for i, col := range cols {
+		cols[i] = b.quote(col)
 	}
-	return table
+	return strings.Join(cols, ",")
 }
 
 func (b *Builder) buildOrder() string {
 	if len(b.orderBy) == 0 {
 		return ""
 	}
-	return "ORDER BY " + strings.Join(b.orderBy, ",")
+	orderBy := make([]string, len(b.orderBy))
+	for i, order := range b.orderBy {
+		b.args = append(b.args, order.args...)
+		if b.ConditionBuilder.quoteIdentifiers {
+			order.expr = quoteIdentifier(order.expr, b.ConditionBuilder.dialect)
+		}
+		if order.nulls == "" {
+			order.nulls = b.nullsOrder
+		}
+		orderBy[i] = order.String()
+	}
+	return b.annotate("order by", "ORDER BY "+strings.Join(orderBy, ","))
 }
 
 func (b *Builder) buildLimit() string {
+	if b.limitPercent > 0 {
+		d, ok := b.dialect().(PercentDialect)
+		if !ok {
+			b.fail(errPercentDialect)
+		}
+		return b.annotate("limit", d.LimitPercent(b.limitPercent, b.offset))
+	}
 	if b.limit <= 0 {
 		return ""
 	}
-	sql := fmt.Sprintf("LIMIT %d", b.limit)
+	d := b.dialect()
+	marker, ok := d.(limitOffsetMarker)
+	if !ok {
+		return b.annotate("limit", d.LimitOffset(b.limit, b.offset))
+	}
+	// tip: 先用可区分的标记生成子句，再按标记出现的顺序收集参数，如MySQL的 LIMIT offset, limit
+	const limitMarker, offsetMarker = argMarker + "limit", argMarker + "offset"
+	offset := ""
 	if b.offset > 0 {
-		sql += fmt.Sprintf(" OFFSET %d", b.offset)
+		offset = offsetMarker
 	}
-	return sql
+	sql := marker.limitOffsetMarker(limitMarker, offset)
+	limitAt, offsetAt := strings.Index(sql, limitMarker), strings.Index(sql, offsetMarker)
+	if offsetAt >= 0 && offsetAt < limitAt {
+		b.args = append(b.args, b.offset, b.limit)
+	} else if offsetAt >= 0 {
+		b.args = append(b.args, b.limit, b.offset)
+	} else {
+		b.args = append(b.args, b.limit)
+	}
+	sql = strings.NewReplacer(limitMarker, argMarker, offsetMarker, argMarker).Replace(sql)
+	return b.annotate("limit", sql)
 }
 
 func (b *Builder) query() string {
@@ -260,12 +784,35 @@ func (b *Builder) query() string {
 	}, " ")
 }
 
+// as为空时不添加别名，用于table本身已带别名的情况，如ValuesTable；
+// on只有CROSS、NATURAL JOIN可以为空，否则Build时为结构错误
 func (b *Builder) Join(joinType, table, as, on string) *Builder {
-	b.join = append(b.join,
-		fmt.Sprintf("%s JOIN %s AS %s ON %s", joinType, table, as, on))
+	b.join = append(b.join, joinClause{joinType: joinType, table: table, as: as, on: on})
 	return b
 }
 
+// CROSS JOIN、NATURAL JOIN不需要ON条件
+func (j joinClause) withoutOn() bool {
+	joinType := strings.ToUpper(strings.TrimSpace(j.joinType))
+	return strings.HasPrefix(joinType, "CROSS") || strings.HasPrefix(joinType, "NATURAL")
+}
+
+// 反连接，生成 NOT EXISTS (SELECT 1 FROM table AS as WHERE on) 条件，
+// 用于查找没有关联子表行的记录，代替 LEFT JOIN ... WHERE child.id IS NULL
+func (b *Builder) AntiJoin(table, as, on string) *Builder {
+	return b.NotExists(b.joinSubQuery(table, as, on))
+}
+
+// 半连接，生成 EXISTS (SELECT 1 FROM table AS as WHERE on) 条件，
+// 用于查找至少有一条关联子表行的记录，与INNER JOIN不同，主表的行不会因多条子表行而重复
+func (b *Builder) SemiJoin(table, as, on string) *Builder {
+	return b.Exists(b.joinSubQuery(table, as, on))
+}
+
+func (b *Builder) joinSubQuery(table, as, on string) *Builder {
+	return NewBuilder().Schema(b.schema).Select(table).Alias(as).Fields("1").Where(on)
+}
+
 func (b *Builder) LeftJoin(table, as, on string) *Builder {
 	return b.Join("LEFT", table, as, on)
 }
@@ -274,6 +821,15 @@ func (b *Builder) RightJoin(table, as, on string) *Builder {
 	return b.Join("RIGHT", table, as, on)
 }
 
+func (b *Builder) FullJoin(table, as, on string) *Builder {
+	return b.Join("FULL", table, as, on)
+}
+
+// CROSS JOIN没有ON条件
+func (b *Builder) CrossJoin(table, as string) *Builder {
+	return b.Join("CROSS", table, as, "")
+}
+
 func (b *Builder) InnerJoin(table, as, on string) *Builder {
 	return b.Join("INNER", table, as, on)
 }
@@ -283,51 +839,210 @@ func (b *Builder) GroupBy(group ...string) *Builder {
 	return b
 }
 
+// 按时间粒度分组，同时添加查询字段和GROUP BY
+func (b *Builder) GroupByDateTrunc(unit, col, alias string) *Builder {
+	b.fields = append(b.fields, selectField{expr: DateTrunc(unit, col, ""), alias: alias})
+	b.groupBy = append(b.groupBy, DateTrunc(unit, col, ""))
+	return b
+}
+
+// 添加HAVING条件，多次调用或与HavingEqual、HavingGt等混用时用AND连接
 func (b *Builder) Having(having string) *Builder {
-	b.having = having
+	b.HavingBuilder.Where(having)
+	return b
+}
+
+// 添加HAVING等值条件，如 HavingEqual("COUNT(1)", 1)
+func (b *Builder) HavingEqual(expr string, value interface{}) *Builder {
+	b.HavingBuilder.Equal(expr, value)
+	return b
+}
+
+// 添加HAVING大于条件，如 HavingGt("COUNT(1)", 5)
+func (b *Builder) HavingGt(expr string, value interface{}) *Builder {
+	b.HavingBuilder.Gt(expr, value)
 	return b
 }
 
 func (b *Builder) Fields(fields ...string) *Builder {
-	b.fields = append(b.fields, fields...)
+	for _, field := range fields {
+		b.fields = append(b.fields, selectField{expr: field})
+	}
+	return b
+}
+
+// 添加以prefix限定的查询字段，如 FieldsPrefixed("u", "id", "name") => u.id,u.name，
+// 已带前缀的字段及表达式原样添加
+func (b *Builder) FieldsPrefixed(prefix string, fields ...string) *Builder {
+	for _, field := range fields {
+		if isBareIdentifier(field) && !strings.Contains(field, ".") {
+			field = prefix + "." + field
+		}
+		b.fields = append(b.fields, selectField{expr: field})
+	}
+	return b
+}
+
+// 添加model(struct或struct指针)的所有导出字段作为查询字段，字段名规则与插入时一致，
+// 即StructExportedFields转为蛇形，如 CreatedAt => created_at
+func (b *Builder) FieldsFromStruct(model interface{}) *Builder {
+	return b.Fields(modelColumns(model)...)
+}
+
+// model(struct或struct指针)所有导出字段对应的列名
+func modelColumns(model interface{}) []string {
+	t := reflect.TypeOf(model)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		log.Panic("sqlol: model must be struct.")
+	}
+	return CamelsToSnakes(structExportedFields(t))
+}
+
+// cond为true时才添加查询字段
+func (b *Builder) FieldIf(cond bool, field string) *Builder {
+	if cond {
+		b.Fields(field)
+	}
+	return b
+}
+
+// 声明主表的主键列，带JOIN时BuildCount使用 COUNT(DISTINCT 主键) 避免重复计数，
+// PartitionedUpsert按该列更新
+func (b *Builder) PrimaryKey(col string) *Builder {
+	b.primaryKey = col
+	return b
+}
+
+// 以主表别名(或表名)限定的主键列，如 u.id
+func (b *Builder) primaryKeyExpr() string {
+	if strings.Contains(b.primaryKey, ".") {
+		return b.primaryKey
+	}
+	if b.tableAlias != "" {
+		return b.tableAlias + "." + b.primaryKey
+	}
+	return b.table + "." + b.primaryKey
+}
+
+// SELECT DISTINCT
+func (b *Builder) Distinct() *Builder {
+	b.distinct = true
+	return b
+}
+
+// SELECT DISTINCT ON (fields)，通常需要配合以fields开头的OrderBy使用
+func (b *Builder) DistinctOn(fields ...string) *Builder {
+	b.distinctOn = append(b.distinctOn, fields...)
+	return b
+}
+
+// 添加带别名的查询字段
+func (b *Builder) FieldAs(expr, alias string) *Builder {
+	b.fields = append(b.fields, selectField{expr: expr, alias: alias})
 	return b
 }
+
 func (b *Builder) ForUpdate() *Builder {
 	b.isForUpdate = true
+	b.isForShare = false
+	return b
+}
+
+// 共享锁 FOR SHARE，与ForUpdate互斥，后调用的生效
+func (b *Builder) ForShare() *Builder {
+	b.isForShare = true
+	b.isForUpdate = false
+	return b
+}
+
+// 跳过已被锁定的行，如 FOR UPDATE SKIP LOCKED，常用于任务队列，需配合ForUpdate或ForShare，否则Build时为结构错误
+func (b *Builder) SkipLocked() *Builder {
+	return b.setLockWait("SKIP LOCKED")
+}
+
+// 行已被锁定时立即报错而不等待，如 FOR UPDATE NOWAIT，需配合ForUpdate或ForShare，否则Build时为结构错误
+func (b *Builder) NoWait() *Builder {
+	return b.setLockWait("NOWAIT")
+}
+
+func (b *Builder) setLockWait(wait string) *Builder {
+	if b.lockWait != "" && b.lockWait != wait {
+		log.Panic("sqlol: SKIP LOCKED and NOWAIT cannot be used together")
+	}
+	b.lockWait = wait
 	return b
 }
 
 func (b *Builder) buildForUpdate() string {
+	var sql string
 	if b.isForUpdate {
-		return "FOR UPDATE"
+		sql = "FOR UPDATE"
+	} else if b.isForShare {
+		sql = "FOR SHARE"
+	} else {
+		return ""
 	}
-	return ""
+	if b.lockWait != "" {
+		sql += " " + b.lockWait
+	}
+	return b.annotate("lock", sql)
 }
 
 func (b *Builder) buildJoin() string {
 	if len(b.join) == 0 {
 		return ""
 	}
-	return strings.Join(b.join, " ")
+	var joins []string
+	for _, j := range b.join {
+		join := fmt.Sprintf("%s JOIN %s", j.joinType, b.aliasExpr(b.quote(b.qualify(j.table)), b.quote(j.as)))
+		if j.on != "" {
+			join += " ON " + j.on
+		}
+		joins = append(joins, join)
+	}
+	return b.annotate("join", strings.Join(joins, " "))
 }
 func (b *Builder) buildGroup() string {
 	if len(b.groupBy) == 0 {
 		return ""
 	}
-	return "GROUP BY " + strings.Join(b.groupBy, ",")
+	groupBy := b.groupBy
+	if b.ConditionBuilder.quoteIdentifiers {
+		groupBy = make([]string, len(b.groupBy))
+		for i, group := range b.groupBy {
+			groupBy[i] = quoteIdentifier(group, b.ConditionBuilder.dialect)
+		}
+	}
+	return b.annotate("group by", "GROUP BY "+strings.Join(groupBy, ","))
 }
 
 func (b *Builder) buildHaving() string {
-	if b.having == "" {
+	condition, args := b.HavingBuilder.build()
+	if condition == "" {
 		return ""
 	}
-	return "HAVING " + b.having
+	condition = b.HavingBuilder.resolveIdents(condition)
+	b.args = append(b.args, args...)
+	return b.annotate("having", "HAVING "+condition)
 }
 
 func (b *Builder) selectFields() string {
 	fields := "*"
 	if len(b.fields) > 0 {
-		fields = strings.Join(b.fields, ",")
+		var s []string
+		for _, field := range b.fields {
+			s = append(s, b.aliasExpr(b.quote(field.expr), b.quote(field.alias)))
+		}
+		fields = strings.Join(s, ",")
+	}
+	if len(b.distinctOn) > 0 {
+		return fmt.Sprintf("%s DISTINCT ON (%s) %s", b.manipulation, strings.Join(b.quoteAll(b.distinctOn), ", "), fields)
+	}
+	if b.distinct {
+		return fmt.Sprintf("%s DISTINCT %s", b.manipulation, fields)
 	}
 	return fmt.Sprintf("%s %s", b.manipulation, fields)
 }
@@ -344,41 +1059,299 @@ func (b *Builder) Set(data ...string) *Builder {
 
 func (b *Builder) SetMap(data map[string]interface{}) *Builder {
 	for k, v := range data {
-		b.updates = append(b.updates,
-			fmt.Sprintf("%s = %s", k, ToString(v)))
+		b.updates = append(b.updates, markIdent(k)+" = "+argMarker)
+		b.updateArgs = append(b.updateArgs, v)
 	}
 	return b
 }
 
+// 表达式赋值，如 SetExpr("score", "score * ? + ?", 2, 1)，expr中的?依次替换为args，
+// args与SetMap的值一样处理，Build时经ToString转义，BuildArgs时作为参数绑定；
+// 单引号字符串中的?原样保留，??表示?本身，如jsonb的 tags ?? 'a'、tags ??| array['a']
+func (b *Builder) SetExpr(col, expr string, args ...interface{}) *Builder {
+	expr, n := exprArgMarkers(expr)
+	if n != len(args) {
+		log.Panic("sqlol: the number of ? and args mismatch")
+	}
+	b.updates = append(b.updates, markIdent(col)+" = "+expr)
+	b.updateArgs = append(b.updateArgs, args...)
+	return b
+}
+
+// 将expr中单引号字符串外的?替换为参数标记，??替换为?，返回替换后的expr及参数个数
+func exprArgMarkers(expr string) (string, int) {
+	var buf strings.Builder
+	n, quoted := 0, false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted || c != '?':
+		case i+1 < len(expr) && expr[i+1] == '?':
+			i++
+		default:
+			buf.WriteString(argMarker)
+			n++
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String(), n
+}
+
+// 自增，如 counter = counter + 1
+func (b *Builder) Increment(col string, delta interface{}) *Builder {
+	return b.SetExpr(col, markIdent(col)+" + ?", delta)
+}
+
+// 自减，如 stock = stock - 1
+func (b *Builder) Decrement(col string, delta interface{}) *Builder {
+	return b.SetExpr(col, markIdent(col)+" - ?", delta)
+}
+
 func (b *Builder) SetStruct(data interface{}) *Builder {
 	b.updateStruct = data
 	return b
 }
 
+// 与SetStruct相同，但生成逐个的 col = value 赋值，可以与Set、SetMap混用，
+// 字段范围与SetStruct一致，需要限定字段时应在调用前使用Cols()
+func (b *Builder) SetStructAsAssignments(data interface{}) *Builder {
+	assignments, args := structAssignmentArgs(data, b.updateCols(data))
+	b.updates = append(b.updates, assignments...)
+	b.updateArgs = append(b.updateArgs, args...)
+	return b
+}
+
+// values可以是struct、struct切片，也可以是SELECT类型的*Builder(INSERT ... SELECT)
 func (b *Builder) Values(values interface{}) *Builder {
 	b.values = values
 	return b
 }
 
+// values是否为空的切片或数组
+func (b *Builder) emptyValues() bool {
+	v := reflect.ValueOf(b.values)
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() == 0
+}
+
+// 插入的values为空切片时不panic，Build返回空字符串(BuildStatements、BuildBatch返回nil)，
+// 由调用方跳过执行，适用于过滤后可能没有数据需要插入的批量插入
+func (b *Builder) AllowEmptyInsert() *Builder {
+	b.allowEmptyInsert = true
+	return b
+}
+
+// 插入查询结果，如 INSERT INTO table(cols) SELECT ...，即 Insert(table).Cols(cols...).Values(sub)，
+// 之后仍可设置OnConflict、Returning
+func (b *Builder) InsertSelect(table string, cols []string, sub *Builder) *Builder {
+	return b.Insert(table).Cols(cols...).Values(sub)
+}
+
+// 插入struct或struct切片，onlyCols不为空时只插入这些字段，字段不存在时panic
+func (b *Builder) InsertStruct(data interface{}, onlyCols ...string) *Builder {
+	if len(onlyCols) > 0 {
+		t := reflect.TypeOf(data)
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			log.Panic("sqlol: data must be struct or struct slice.")
+		}
+		zero := reflect.New(t).Elem()
+		for _, col := range onlyCols {
+			if !structField(zero, col).IsValid() {
+				log.Panic("sqlol: no field '" + col + "' in struct")
+			}
+		}
+		b.cols = append(b.cols, onlyCols...)
+	}
+	b.values = data
+	return b
+}
+
+// 按shouldUpdate将struct切片拆分为插入和更新两部分，
+// 返回插入语句(没有需要插入的行时为空)和逐行的更新语句(按PrimaryKey更新，未设置时为id)，
+// 表名、Cols、Dialect及QuoteIdentifiers取自当前builder，struct中没有主键对应的字段时报ErrNoKeyField
+func (b *Builder) PartitionedUpsert(
+	data interface{}, shouldUpdate func(interface{}) bool) (string, []string) {
+	insert, updates, err := b.partitionedUpsert(data, shouldUpdate)
+	if err != nil {
+		b.handleErr(err)
+		return "", nil
+	}
+	return insert, updates
+}
+
+func (b *Builder) partitionedUpsert(data interface{}, shouldUpdate func(interface{}) bool) (
+	insert string, updates []string, err error) {
+	defer recoverBuildError(&err)
+	value := reflect.ValueOf(data)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		log.Panic("sqlol: data must be struct slice.")
+	}
+	inserts := reflect.MakeSlice(reflect.SliceOf(value.Type().Elem()), 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		row := value.Index(i)
+		if !shouldUpdate(row.Interface()) {
+			inserts = reflect.Append(inserts, row)
+			continue
+		}
+		elem := reflect.Indirect(row)
+		if elem.Kind() == reflect.Interface {
+			elem = reflect.Indirect(elem.Elem())
+		}
+		key := b.upsertKey()
+		id := structKeyField(elem, key)
+		if !id.IsValid() {
+			b.fail(ErrNoKeyField)
+		}
+		ub := NewBuilder().Dialect(b.ConditionBuilder.dialect).Schema(b.schema).Update(b.table)
+		if b.ConditionBuilder.quoteIdentifiers {
+			ub.QuoteIdentifiers()
+		}
+		update, err := ub.Cols(b.cols...).
+			SetStruct(row.Interface()).
+			Equal(key, id.Interface()).
+			BuildE()
+		if err != nil {
+			return "", nil, err
+		}
+		updates = append(updates, update)
+	}
+	if inserts.Len() > 0 {
+		if insert, err = b.Clone().Insert(b.table).Values(inserts.Interface()).BuildE(); err != nil {
+			return "", nil, err
+		}
+	}
+	return insert, updates, nil
+}
+
+// PartitionedUpsert更新时的主键列，去掉表名限定
+func (b *Builder) upsertKey() string {
+	if b.primaryKey == "" {
+		return "id"
+	}
+	return b.primaryKey[strings.LastIndexByte(b.primaryKey, '.')+1:]
+}
+
 func (b *Builder) insert() string {
 	if b.values == nil {
-		log.Panic("sql builder: inserting structValues are required")
+		b.fail(ErrNoValues)
 		return ""
 	}
+	if b.emptyValues() {
+		if !b.allowEmptyInsert {
+			b.fail(ErrNoValues)
+		}
+		return ""
+	}
+	if sub, ok := b.values.(*Builder); ok {
+		if sub == nil {
+			b.fail(ErrNoValues)
+			return ""
+		}
+		return b.insertSelect(sub)
+	}
 	cols := b.insertCols()
 	if len(cols) == 0 {
-		log.Panic("sqlol: inserting fields are required")
+		b.fail(ErrNoCols)
 		return ""
 	}
+	values, args := structValueArgs(b.values, cols)
+	b.args = append(b.args, args...)
 	return fmt.Sprintf("INSERT INTO %s(%s) VALUES %s %s %s",
 		b.tableName(),
-		strings.Join(CamelsToSnakes(cols), ","),
-		StructValues(b.values, cols),
-		b.onConflict,
+		b.columnList(cols),
+		values,
+		b.buildOnConflict(cols),
 		b.buildReturning(),
 	)
 }
 
+// 生成批量导入的COPY语句头，如 COPY a.user (name,age) FROM STDIN，
+// 列与插入语句相同，取自Cols或Values，数据由调用方通过CopyFrom等方式传输；
+// 无法确定列(未设置Cols且Values为空)时为ErrNoCols
+func (b *Builder) BuildCopy() string {
+	sql, err := b.buildCopy()
+	if err != nil {
+		b.handleErr(err)
+	}
+	return sql
+}
+
+func (b *Builder) buildCopy() (sql string, err error) {
+	defer recoverBuildError(&err)
+	if b.manipulation != manipulationInsert {
+		log.Panic("sqlol: COPY requires an INSERT")
+	}
+	var cols []string
+	if len(b.cols) > 0 || b.values != nil {
+		cols = b.insertCols()
+	}
+	if len(cols) == 0 {
+		b.fail(ErrNoCols)
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN", b.tableName(), b.columnList(cols)), nil
+}
+
+// 分批生成插入语句，每条最多chunkSize行，各条保留Cols、OnConflict、Returning，
+// chunkSize为0时不分批，与Build相同；任一批出错时返回nil，错误与Build一样处理(记录在b上)
+func (b *Builder) BuildBatch(chunkSize int) []string {
+	var statements []string
+	for _, chunk := range b.chunks(chunkSize) {
+		sql, err := chunk.BuildE()
+		if err != nil {
+			b.handleErr(err)
+			return nil
+		}
+		if sql != "" {
+			statements = append(statements, sql)
+		}
+	}
+	return statements
+}
+
+// 将插入的values切分为每批最多chunkSize行，每批为一个独立的builder，
+// chunkSize<=0或values不是切片时不切分
+func (b *Builder) chunks(chunkSize int) []*Builder {
+	v := reflect.ValueOf(b.values)
+	if chunkSize <= 0 || v.Kind() != reflect.Slice || v.Len() <= chunkSize {
+		return []*Builder{b}
+	}
+	var chunks []*Builder
+	for i := 0; i < v.Len(); i += chunkSize {
+		end := i + chunkSize
+		if end > v.Len() {
+			end = v.Len()
+		}
+		chunk := b.Clone()
+		chunk.values = v.Slice(i, end).Interface()
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func (b *Builder) insertSelect(sub *Builder) string {
+	table := b.tableName()
+	if len(b.cols) > 0 {
+		table += "(" + b.columnList(b.cols) + ")"
+	}
+	subSQL, args := sub.render((*Builder).build)
+	b.args = append(b.args, args...)
+	return strings.Join([]string{
+		"INSERT INTO",
+		table,
+		subSQL,
+		b.buildOnConflict(b.cols),
+		b.buildReturning(),
+	}, " ")
+}
+
 func (b *Builder) update() string {
 	return strings.Join([]string{
 		b.manipulation,
@@ -395,7 +1368,7 @@ func (b *Builder) update() string {
 func (b *Builder) delete() string {
 	where := b.buildWhere()
 	if where == "" {
-		log.Panic("sqlol: deleting condition is required")
+		b.fail(ErrNoDeleteCondition)
 		return ""
 	}
 	return strings.Join([]string{
@@ -411,15 +1384,22 @@ func (b *Builder) delete() string {
 
 func (b *Builder) buildUpdates() string {
 	if b.updateStruct != nil {
-		cols := b.updateCols()
-		return fmt.Sprintf("(%s) = %s",
-			strings.Join(CamelsToSnakes(cols), ","),
-			StructValues(b.updateStruct, cols))
+		cols := b.updateCols(b.updateStruct)
+		if len(cols) == 1 {
+			// tip: 单个字段的行构造器 (a) = (1) 在部分版本中不被接受
+			assignments, args := structAssignmentArgs(b.updateStruct, cols)
+			b.args = append(b.args, args...)
+			return assignments[0]
+		}
+		values, args := structValueArgs(b.updateStruct, cols)
+		b.args = append(b.args, args...)
+		return fmt.Sprintf("(%s) = %s", b.columnList(cols), values)
 	}
 	if len(b.updates) == 0 {
-		log.Panic("sqlol: updating structValues are required")
+		b.fail(ErrNoValues)
 		return ""
 	}
+	b.args = append(b.args, b.updateArgs...)
 	return strings.Join(b.updates, ",")
 }
 
@@ -427,7 +1407,11 @@ func (b *Builder) buildReturning() string {
 	if len(b.returning) == 0 {
 		return ""
 	}
-	return `RETURNING ` + strings.Join(b.returning, ",")
+	sql, err := b.dialect().Returning(b.quoteAll(b.returning))
+	if err != nil {
+		b.fail(err)
+	}
+	return b.annotate("returning", sql)
 }
 
 func (b *Builder) insertCols() []string {
@@ -437,6 +1421,9 @@ func (b *Builder) insertCols() []string {
 		value := reflect.ValueOf(b.values)
 		switch value.Kind() {
 		case reflect.Slice, reflect.Array:
+			if value.Len() == 0 {
+				return nil
+			}
 			s = value.Index(0).Interface()
 		default:
 			s = b.values
@@ -446,20 +1433,93 @@ func (b *Builder) insertCols() []string {
 	return cols
 }
 
-func (b *Builder) updateCols() []string {
+func (b *Builder) updateCols(data interface{}) []string {
 	cols := b.cols
 	if len(cols) == 0 {
-		cols = StringSliceDiff(StructExportedFields(b.updateStruct), []string{"CreatedBy", "CreatedAt"})
+		cols = StringSliceDiff(StructExportedFields(data), []string{"CreatedBy", "CreatedAt"})
 	}
 	return cols
 }
 
+// 设置ON CONFLICT子句，fields为冲突目标，列名(如 a,b)会加上括号；
+// 以括号或ON CONSTRAINT开头的完整冲突目标(如 (a,b) WHERE deleted_at IS NULL)原样使用
 func (b *Builder) OnConflict(fields string, do string) *Builder {
-	if fields == "" {
+	b.conflictUpdate = nil
+	target := strings.TrimSpace(fields)
+	switch {
+	case target == "":
 		b.onConflict = "ON CONFLICT DO " + do
-	} else {
-		b.onConflict = fmt.Sprintf("ON CONFLICT (%s) DO %s", fields, do)
+	case strings.HasPrefix(target, "(") ||
+		strings.HasPrefix(strings.ToUpper(target), "ON CONSTRAINT "):
+		b.onConflict = fmt.Sprintf("ON CONFLICT %s DO %s", target, do)
+	default:
+		b.onConflict = fmt.Sprintf("ON CONFLICT (%s) DO %s", target, do)
+	}
+	return b
+}
+
+// 设置 ON CONFLICT (conflictFields) DO UPDATE SET col = EXCLUDED.col,...，
+// updateCols为空时更新除冲突字段外的所有插入字段，在Build时根据插入字段生成
+func (b *Builder) OnConflictDoUpdate(conflictFields []string, updateCols ...string) *Builder {
+	b.onConflict = ""
+	b.conflictUpdate = &conflictUpdate{fields: conflictFields, cols: updateCols}
+	return b
+}
+
+func (b *Builder) buildOnConflict(insertCols []string) string {
+	u := b.conflictUpdate
+	if u == nil {
+		if b.onConflict != "" && b.dialect() != Postgres {
+			b.fail(errConflictDialect)
+		}
+		return b.annotate("on conflict", b.onConflict)
+	}
+	fields := CamelsToSnakes(u.fields)
+	cols := CamelsToSnakes(u.cols)
+	if len(cols) == 0 {
+		for _, col := range CamelsToSnakes(insertCols) {
+			if !containsString(fields, col) {
+				cols = append(cols, col)
+			}
+		}
+	}
+	if len(cols) == 0 {
+		log.Panic("sqlol: on conflict updating cols are required")
+		return ""
+	}
+	d := b.dialect()
+	preserve := CamelsToSnakes(u.preserve)
+	for _, col := range preserve {
+		if !containsString(cols, col) {
+			log.Panicf("sqlol: preserved col %s is not updated on conflict", col)
+		}
+	}
+	target := b.quote(b.tableAlias)
+	if target == "" {
+		target = b.quote(b.qualify(b.table))
+	}
+	assignments := make([]string, len(cols))
+	for i, col := range cols {
+		quoted := b.quote(col)
+		value := d.Excluded(quoted)
+		if containsString(preserve, col) {
+			value = fmt.Sprintf("COALESCE(%s, %s.%s)", value, target, quoted)
+		}
+		assignments[i] = quoted + " = " + value
+	}
+	return b.annotate("on conflict", d.Upsert(b.quoteAll(fields), assignments))
+}
+
+// 冲突更新时保留cols的已有值，只用非NULL的新值覆盖，如 col = COALESCE(EXCLUDED.col, t.col)，
+// 需要在OnConflictDoUpdate之后调用，cols需在更新字段中
+func (b *Builder) OnConflictPreserve(cols ...string) *Builder {
+	if b.conflictUpdate == nil {
+		log.Panic("sqlol: OnConflictPreserve requires OnConflictDoUpdate")
+		return b
 	}
+	u := *b.conflictUpdate
+	u.preserve = append(copyStringSlice(u.preserve), cols...)
+	b.conflictUpdate = &u
 	return b
 }
 
@@ -467,16 +1527,153 @@ func (b *Builder) OnConflictDoNothing() *Builder {
 	return b.OnConflict("", "NOTHING")
 }
 
+// 返回所有字段，即 RETURNING *
+func (b *Builder) ReturningAll() *Builder {
+	return b.Returning("*")
+}
+
+// 返回model(struct或struct指针)的所有导出字段，列名与FieldsFromStruct一致，
+// 便于将插入、更新后的行扫描回同一个结构体
+func (b *Builder) ReturningStruct(model interface{}) *Builder {
+	return b.Returning(modelColumns(model)...)
+}
+
+const insertFlag = "(xmax = 0) AS sqlolinserted"
+
 func (b *Builder) Returning(fields ...string) *Builder {
 	b.returning = append(b.returning, fields...)
 	return b
 }
 
+// 返回区分插入与更新的标记列 (xmax = 0) AS sqlolinserted，用于ON CONFLICT DO UPDATE，
+// 新插入的行为true，冲突后更新的行为false
+func (b *Builder) ReturningInsertFlag() *Builder {
+	return b.Returning(insertFlag)
+}
+
+// 基于更新语句生成同时返回更新前后值的查询，用于审计日志，如
+// WITH sqlolold AS (SELECT * FROM t WHERE ... FOR UPDATE),
+// sqlolnew AS (UPDATE t SET ... WHERE ... RETURNING *)
+// SELECT sqlolold.col AS old_col,sqlolnew.col AS new_col FROM sqlolold INNER JOIN sqlolnew ON ...，
+// 同一语句中的CTE使用同一快照，sqlolold中为更新前的值；key为关联前后两行的主键列
+func (b *Builder) UpdateReturningOld(key string, cols ...string) *Builder {
+	if b.manipulation != manipulationUpdate {
+		log.Panic("sqlol: must be an update operation")
+		return nil
+	}
+	if len(cols) == 0 {
+		log.Panic("sqlol: returning cols are required")
+		return nil
+	}
+	old := NewBuilder().Schema(b.schema).Select(b.table).Alias(b.tableAlias).ForUpdate()
+	old.ConditionBuilder = b.ConditionBuilder.clone()
+	upd := b.Clone()
+	upd.returning = []string{"*"}
+	q := NewBuilder().
+		With("sqlolold", old).
+		With("sqlolnew", upd).
+		Select("sqlolold").
+		InnerJoin("sqlolnew", "", fmt.Sprintf("sqlolold.%s = sqlolnew.%s", key, key))
+	for _, col := range cols {
+		q.FieldAs("sqlolold."+col, "old_"+col).FieldAs("sqlolnew."+col, "new_"+col)
+	}
+	return q
+}
+
+// 基于删除语句生成"先归档再删除"的语句，如
+// WITH d AS (DELETE FROM t WHERE ... RETURNING *) INSERT INTO archive SELECT * FROM d，
+// 在同一语句中完成，要求删除条件不能为空
+func (b *Builder) DeleteArchiving(archiveTable string) *Builder {
+	if b.manipulation != manipulationDelete {
+		log.Panic("sqlol: must be a delete operation")
+		return nil
+	}
+	if len(b.ConditionBuilder.wheres) == 0 {
+		log.Panic("sqlol: deleting condition is required")
+		return nil
+	}
+	del := b.Clone()
+	del.returning = []string{"*"}
+	return NewBuilder().Schema(b.schema).
+		With("d", del).
+		Insert(archiveTable).
+		Values(NewBuilder().Select("d"))
+}
+
+// 生成"插入或获取"语句：插入冲突时返回已存在的行，不冲突时返回新插入的行，
+// lookup为查找已存在行的条件(通常为唯一键)。
+// 未设置OnConflict时默认DO NOTHING，未设置Returning时默认返回*，lookup为空时报ErrNoLookup
+func (b *Builder) BuildInsertOrGet(lookup *ConditionBuilder) string {
+	sql, args, err := b.buildInsertOrGet(lookup)
+	if err != nil {
+		b.handleErr(err)
+		return ""
+	}
+	return inlineArgs(sql, args, b.dialect())
+}
+
+// 生成参数化的"插入或获取"语句，插入的值与lookup的条件值按占位顺序返回
+func (b *Builder) BuildInsertOrGetArgs(lookup *ConditionBuilder) (string, []interface{}) {
+	sql, args, err := b.buildInsertOrGet(lookup)
+	if err != nil {
+		b.handleErr(err)
+		return "", nil
+	}
+	return bindArgs(sql, args, b.dialect(), true)
+}
+
+func (b *Builder) buildInsertOrGet(lookup *ConditionBuilder) (sql string, args []interface{}, err error) {
+	defer recoverBuildError(&err)
+	sql, args = b.render(func(r *Builder) string {
+		return r.insertOrGet(lookup)
+	})
+	return sql, args, nil
+}
+
+func (b *Builder) insertOrGet(lookup *ConditionBuilder) string {
+	if b.manipulation != manipulationInsert {
+		log.Panic("sqlol: must be an insert operation")
+	}
+	if lookup == nil {
+		b.fail(ErrNoLookup)
+	}
+	ins := b.Clone()
+	if ins.onConflict == "" && ins.conflictUpdate == nil {
+		ins.OnConflictDoNothing()
+	}
+	if len(ins.returning) == 0 {
+		ins.Returning("*")
+	}
+	insert, args := ins.render((*Builder).build)
+	b.args = append(b.args, args...)
+	// tip: 条件中的字段标记留到render时按b的QuoteIdentifiers替换
+	condition, args := lookup.build()
+	if condition == "" {
+		b.fail(ErrNoLookup)
+	}
+	b.args = append(b.args, args...)
+	fields := strings.Join(b.quoteAll(ins.returning), ",")
+	return fmt.Sprintf("WITH sqlolins AS (%s) "+
+		"SELECT %s FROM sqlolins UNION ALL "+
+		"SELECT %s FROM %s WHERE %s AND NOT EXISTS (SELECT 1 FROM sqlolins)",
+		insert, fields, fields, b.quote(b.qualify(b.table)), condition)
+}
+
 func (b *Builder) Where(strs ...string) *Builder {
 	b.ConditionBuilder.Where(strs...)
 	return b
 }
 
+func (b *Builder) WhereFalse() *Builder {
+	b.ConditionBuilder.WhereFalse()
+	return b
+}
+
+func (b *Builder) WhereTrue() *Builder {
+	b.ConditionBuilder.WhereTrue()
+	return b
+}
+
 func (b *Builder) WhereMap(where map[string]interface{}) *Builder {
 	b.ConditionBuilder.WhereMap(where)
 	return b
@@ -487,6 +1684,29 @@ func (b *Builder) TryMap(where map[string]interface{}) *Builder {
 	return b
 }
 
+// 开启标识符转义，除条件中的字段名外，表名、JOIN的表、别名、select字段及插入字段也会被转义，
+// 可用于 order、select 等保留字或大小写混合的名称，函数调用、表达式等非裸标识符原样生成
+func (b *Builder) QuoteIdentifiers() *Builder {
+	b.ConditionBuilder.QuoteIdentifiers()
+	b.HavingBuilder.QuoteIdentifiers()
+	return b
+}
+
+func (b *Builder) Combinator(op string) *Builder {
+	b.ConditionBuilder.Combinator(op)
+	return b
+}
+
+func (b *Builder) BeginGroup() *Builder {
+	b.ConditionBuilder.BeginGroup()
+	return b
+}
+
+func (b *Builder) EndGroup() *Builder {
+	b.ConditionBuilder.EndGroup()
+	return b
+}
+
 func (b *Builder) Or(strs ...string) *Builder {
 	b.ConditionBuilder.Or(strs...)
 	return b
@@ -497,11 +1717,131 @@ func (b *Builder) Equal(dbField string, value interface{}) *Builder {
 	return b
 }
 
+func (b *Builder) EqualAll(dbFields []string, value interface{}) *Builder {
+	b.ConditionBuilder.EqualAll(dbFields, value)
+	return b
+}
+
+func (b *Builder) EqualAny(dbFields []string, value interface{}) *Builder {
+	b.ConditionBuilder.EqualAny(dbFields, value)
+	return b
+}
+
+func (b *Builder) EqualTyped(dbField, sqlType string, value interface{}) *Builder {
+	b.ConditionBuilder.EqualTyped(dbField, sqlType, value)
+	return b
+}
+
+func (b *Builder) EqualEnum(dbField, enumType string, value interface{}) *Builder {
+	b.ConditionBuilder.EqualEnum(dbField, enumType, value)
+	return b
+}
+
 func (b *Builder) TryEqual(dbField string, value interface{}) *Builder {
 	b.ConditionBuilder.TryEqual(dbField, value)
 	return b
 }
 
+func (b *Builder) NotEqual(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.NotEqual(dbField, value)
+	return b
+}
+
+func (b *Builder) TryNotEqual(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.TryNotEqual(dbField, value)
+	return b
+}
+
+func (b *Builder) DedupeConditions() *Builder {
+	b.ConditionBuilder.DedupeConditions()
+	return b
+}
+
+func (b *Builder) InCoalesce(dbField string, def interface{}, values interface{}) *Builder {
+	b.ConditionBuilder.InCoalesce(dbField, def, values)
+	return b
+}
+
+func (b *Builder) SearchMode(mode SearchMode) *Builder {
+	b.ConditionBuilder.SearchMode(mode)
+	return b
+}
+
+func (b *Builder) MultiSearch(dbFields []string, value string) *Builder {
+	b.ConditionBuilder.MultiSearch(dbFields, value)
+	return b
+}
+
+func (b *Builder) TryMultiSearch(dbFields []string, value string) *Builder {
+	b.ConditionBuilder.TryMultiSearch(dbFields, value)
+	return b
+}
+
+func (b *Builder) CompareColumns(left, op, right string) *Builder {
+	b.ConditionBuilder.CompareColumns(left, op, right)
+	return b
+}
+
+func (b *Builder) Gt(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.Gt(dbField, value)
+	return b
+}
+
+func (b *Builder) TryGt(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.TryGt(dbField, value)
+	return b
+}
+
+func (b *Builder) Gte(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.Gte(dbField, value)
+	return b
+}
+
+func (b *Builder) TryGte(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.TryGte(dbField, value)
+	return b
+}
+
+func (b *Builder) Lt(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.Lt(dbField, value)
+	return b
+}
+
+func (b *Builder) TryLt(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.TryLt(dbField, value)
+	return b
+}
+
+func (b *Builder) Lte(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.Lte(dbField, value)
+	return b
+}
+
+func (b *Builder) TryLte(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.TryLte(dbField, value)
+	return b
+}
+
+func (b *Builder) IsNull(dbField string) *Builder {
+	b.ConditionBuilder.IsNull(dbField)
+	return b
+}
+
+func (b *Builder) IsNotNull(dbField string) *Builder {
+	b.ConditionBuilder.IsNotNull(dbField)
+	return b
+}
+
+func (b *Builder) IsTrue(dbField string) *Builder {
+	b.ConditionBuilder.IsTrue(dbField)
+	return b
+}
+
+func (b *Builder) IsFalse(dbField string) *Builder {
+	b.ConditionBuilder.IsFalse(dbField)
+	return b
+}
+
 func (b *Builder) Like(dbField, value string) *Builder {
 	b.ConditionBuilder.Like(dbField, value)
 	return b
@@ -512,6 +1852,26 @@ func (b *Builder) TryLike(dbField string, value string) *Builder {
 	return b
 }
 
+func (b *Builder) ILike(dbField, value string) *Builder {
+	b.ConditionBuilder.ILike(dbField, value)
+	return b
+}
+
+func (b *Builder) TryILike(dbField, value string) *Builder {
+	b.ConditionBuilder.TryILike(dbField, value)
+	return b
+}
+
+func (b *Builder) NotLike(dbField, value string) *Builder {
+	b.ConditionBuilder.NotLike(dbField, value)
+	return b
+}
+
+func (b *Builder) TryNotLike(dbField, value string) *Builder {
+	b.ConditionBuilder.TryNotLike(dbField, value)
+	return b
+}
+
 func (b *Builder) MultiLike(dbFields []string, value string) *Builder {
 	b.ConditionBuilder.MultiLike(dbFields, value)
 	return b
@@ -522,6 +1882,36 @@ func (b *Builder) TryMultiLike(dbFields []string, value string) *Builder {
 	return b
 }
 
+func (b *Builder) MultiILike(dbFields []string, value string) *Builder {
+	b.ConditionBuilder.MultiILike(dbFields, value)
+	return b
+}
+
+func (b *Builder) TryMultiILike(dbFields []string, value string) *Builder {
+	b.ConditionBuilder.TryMultiILike(dbFields, value)
+	return b
+}
+
+func (b *Builder) Regex(dbField, pattern string) *Builder {
+	b.ConditionBuilder.Regex(dbField, pattern)
+	return b
+}
+
+func (b *Builder) IRegex(dbField, pattern string) *Builder {
+	b.ConditionBuilder.IRegex(dbField, pattern)
+	return b
+}
+
+func (b *Builder) NotRegex(dbField, pattern string) *Builder {
+	b.ConditionBuilder.NotRegex(dbField, pattern)
+	return b
+}
+
+func (b *Builder) NotIRegex(dbField, pattern string) *Builder {
+	b.ConditionBuilder.NotIRegex(dbField, pattern)
+	return b
+}
+
 func (b *Builder) Between(
 	dbField string, start, end interface{}) *Builder {
 	b.ConditionBuilder.Between(dbField, start, end)
@@ -533,16 +1923,46 @@ func (b *Builder) In(dbField string, values interface{}) *Builder {
 	return b
 }
 
+func (b *Builder) MustIn(dbField string, values interface{}) *Builder {
+	b.ConditionBuilder.MustIn(dbField, values)
+	return b
+}
+
 func (b *Builder) TryIn(dbField string, values interface{}) *Builder {
 	b.ConditionBuilder.TryIn(dbField, values)
 	return b
 }
 
+func (b *Builder) TryInNonEmpty(dbField string, values interface{}) *Builder {
+	b.ConditionBuilder.TryInNonEmpty(dbField, values)
+	return b
+}
+
+func (b *Builder) WhereGroup(fn func(cb *ConditionBuilder)) *Builder {
+	b.ConditionBuilder.WhereGroup(fn)
+	return b
+}
+
+func (b *Builder) OrGroup(fn func(cb *ConditionBuilder)) *Builder {
+	b.ConditionBuilder.OrGroup(fn)
+	return b
+}
+
+func (b *Builder) WhereFilters(filters []Filter) *Builder {
+	b.ConditionBuilder.WhereFilters(filters)
+	return b
+}
+
 func (b *Builder) NotIn(dbField string, values interface{}) *Builder {
 	b.ConditionBuilder.NotIn(dbField, values)
 	return b
 }
 
+func (b *Builder) NotInStrict(dbField string, values interface{}) *Builder {
+	b.ConditionBuilder.NotInStrict(dbField, values)
+	return b
+}
+
 func (b *Builder) Any(dbField string, values interface{}) *Builder {
 	b.ConditionBuilder.Any(dbField, values)
 	return b
@@ -553,6 +1973,51 @@ func (b *Builder) TryAny(dbField string, values interface{}) *Builder {
 	return b
 }
 
+func (b *Builder) RangeContains(dbField string, value interface{}) *Builder {
+	b.ConditionBuilder.RangeContains(dbField, value)
+	return b
+}
+
+func (b *Builder) RangeOverlaps(dbField string, other interface{}) *Builder {
+	b.ConditionBuilder.RangeOverlaps(dbField, other)
+	return b
+}
+
+func (b *Builder) Exists(sub *Builder) *Builder {
+	b.ConditionBuilder.Exists(sub)
+	return b
+}
+
+func (b *Builder) InSubQuery(dbField string, subQuery string) *Builder {
+	b.ConditionBuilder.InSubQuery(dbField, subQuery)
+	return b
+}
+
+func (b *Builder) NotInSubQuery(dbField string, subQuery string) *Builder {
+	b.ConditionBuilder.NotInSubQuery(dbField, subQuery)
+	return b
+}
+
+func (b *Builder) InSubBuilder(dbField string, sub *Builder) *Builder {
+	b.ConditionBuilder.InSubBuilder(dbField, sub)
+	return b
+}
+
+func (b *Builder) NotInSubBuilder(dbField string, sub *Builder) *Builder {
+	b.ConditionBuilder.NotInSubBuilder(dbField, sub)
+	return b
+}
+
+func (b *Builder) InTupleSubQuery(dbFields []string, sub *Builder) *Builder {
+	b.ConditionBuilder.InTupleSubQuery(dbFields, sub)
+	return b
+}
+
+func (b *Builder) NotExists(sub *Builder) *Builder {
+	b.ConditionBuilder.NotExists(sub)
+	return b
+}
+
 func (b *Builder) TryTimeRange(dbField string, startTime, endTime time.Time) *Builder {
 	b.ConditionBuilder.TryTimeRange(dbField, startTime, endTime)
 	return b
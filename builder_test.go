@@ -1,7 +1,11 @@
 package sqlol
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -33,7 +37,7 @@ func ExampleBuilder() {
 	// WHERE (t.field1 = 1)
 	// GROUP BY a.name
 	// ORDER BY a.id
-	// HAVING sum_num > 300
+	// HAVING (sum_num > 300)
 	// FOR UPDATE
 
 	builder.Clear()
@@ -123,6 +127,734 @@ func ExampleBuilder() {
 	// WHERE (id = 1)
 }
 
+// 去掉多余空白，便于比较生成的sql
+func trimSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
+
+func TestBuilder_With(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "delete then insert",
+			sql: NewBuilder().
+				With("moved", NewBuilder().Delete("a.orders").
+					Equal("status", "done").Returning("*")).
+				Insert("a.orders_archive").
+				Values(NewBuilder().Select("moved")).
+				Build(),
+			want: "WITH moved AS (DELETE FROM a.orders WHERE (status = 'done') RETURNING *) " +
+				"INSERT INTO a.orders_archive SELECT * FROM moved",
+		},
+		{
+			name: "update then select",
+			sql: NewBuilder().
+				With("updated", NewBuilder().Update("a.user").
+					Set("age = age + 1").Equal("id", 1).Returning("id", "age")).
+				Select("updated").
+				Build(),
+			want: "WITH updated AS (UPDATE a.user SET age = age + 1 WHERE (id = 1) RETURNING id,age) " +
+				"SELECT * FROM updated",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimSQL(tt.sql); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_GroupByDateTrunc(t *testing.T) {
+	sql := NewBuilder().Select("a.orders").
+		Fields("count(1) num").
+		GroupByDateTrunc("day", "created_at", "day").
+		Build()
+	want := "SELECT count(1) num,date_trunc('day', created_at) AS day FROM a.orders " +
+		"GROUP BY date_trunc('day', created_at)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_BuildCountEstimate(t *testing.T) {
+	sql := NewBuilder().Select("a.user").Alias("u").BuildCountEstimate()
+	want := "SELECT reltuples::bigint AS estimate FROM pg_class WHERE oid = 'a.user'::regclass"
+	if sql != want {
+		t.Errorf("BuildCountEstimate() = %v, want %v", sql, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("BuildCountEstimate() with where should panic")
+		}
+	}()
+	NewBuilder().Select("a.user").Equal("id", 1).BuildCountEstimate()
+}
+
+func TestBuilder_OmitAs(t *testing.T) {
+	build := func(omitAs bool) string {
+		b := NewBuilder().Select("a.user").Alias("u").
+			FieldAs("u.name", "user_name").
+			LeftJoin("a.order", "o", "o.user_id = u.id")
+		if omitAs {
+			b.OmitAs()
+		}
+		return trimSQL(b.Build())
+	}
+	want := "SELECT u.name AS user_name FROM a.user AS u LEFT JOIN a.order AS o ON o.user_id = u.id"
+	if got := build(false); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	want = "SELECT u.name user_name FROM a.user u LEFT JOIN a.order o ON o.user_id = u.id"
+	if got := build(true); got != want {
+		t.Errorf("Build() with OmitAs = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_BuildInsertOrGet(t *testing.T) {
+	lookup := &ConditionBuilder{}
+	lookup.Equal("name", "a")
+	sql := NewBuilder().Insert("a.user").
+		Cols("Name", "Age").
+		Values(User{Name: "a", Age: 1}).
+		Returning("id", "name").
+		BuildInsertOrGet(lookup)
+	want := "WITH sqlolins AS (INSERT INTO a.user(name,age) VALUES ('a',1) ON CONFLICT DO NOTHING RETURNING id,name) " +
+		"SELECT id,name FROM sqlolins UNION ALL " +
+		"SELECT id,name FROM a.user WHERE (name = 'a') AND NOT EXISTS (SELECT 1 FROM sqlolins)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildInsertOrGet() = %v, want %v", got, want)
+	}
+
+	sql, args := NewBuilder().Insert("a.user").
+		QuoteIdentifiers().
+		Cols("Name", "Age").
+		Values(User{Name: "a", Age: 1}).
+		Returning("id", "name").
+		BuildInsertOrGetArgs(lookup)
+	want = `WITH sqlolins AS (INSERT INTO "a"."user"("name","age") VALUES ($1,$2) ON CONFLICT DO NOTHING RETURNING "id","name") ` +
+		`SELECT "id","name" FROM sqlolins UNION ALL ` +
+		`SELECT "id","name" FROM "a"."user" WHERE ("name" = $3) AND NOT EXISTS (SELECT 1 FROM sqlolins)`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildInsertOrGetArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a", int8(1), "a"}) {
+		t.Errorf("BuildInsertOrGetArgs() args = %v", args)
+	}
+}
+
+func TestBuilder_NullsOrder(t *testing.T) {
+	sql := NewBuilder().Select("a.user").
+		OrderBy("age DESC", "name", "remark ASC NULLS FIRST").
+		NullsOrder(true).
+		Build()
+	want := "SELECT * FROM a.user ORDER BY age DESC NULLS LAST,name NULLS LAST,remark ASC NULLS FIRST"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_StatementTimeout(t *testing.T) {
+	b := NewBuilder().Select("a.user")
+	if got := b.BuildStatements(); len(got) != 1 {
+		t.Errorf("BuildStatements() = %v, want only the query", got)
+	}
+	got := b.StatementTimeout(1500 * time.Millisecond).BuildStatements()
+	if len(got) != 2 || got[0] != "SET LOCAL statement_timeout = 1500" ||
+		trimSQL(got[1]) != "SELECT * FROM a.user" {
+		t.Errorf("BuildStatements() = %v", got)
+	}
+	if got, want := b.StatementTimeout(time.Minute).BuildStatementTimeout(),
+		"SET LOCAL statement_timeout = 60000"; got != want {
+		t.Errorf("BuildStatementTimeout() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_SelectFunction(t *testing.T) {
+	sql := NewBuilder().SelectFunction("generate_series", "g", 1, 10).Build()
+	if got, want := trimSQL(sql), "SELECT * FROM generate_series(1,10) AS g"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql = NewBuilder().SelectFunction("json_each", "", `{"a":"it's"}`).Build()
+	if got, want := trimSQL(sql), `SELECT * FROM json_each('{"a":"it''s"}')`; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_Debug(t *testing.T) {
+	b := NewBuilder().Select("a.user").Alias("u").
+		LeftJoin("a.order", "o", "o.user_id = u.id").
+		Equal("u.id", 1).
+		OrderBy("u.id").
+		Limit(10)
+	want := "SELECT * FROM a.user AS u LEFT JOIN a.order AS o ON o.user_id = u.id " +
+		"WHERE (u.id = 1) ORDER BY u.id LIMIT 10"
+	if got := trimSQL(b.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	want = "SELECT * FROM a.user AS u /* join */ LEFT JOIN a.order AS o ON o.user_id = u.id " +
+		"/* where */ WHERE (u.id = 1) /* order by */ ORDER BY u.id /* limit */ LIMIT 10"
+	if got := trimSQL(b.Debug().Build()); got != want {
+		t.Errorf("Build() in debug mode = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_SetStructAsAssignments(t *testing.T) {
+	sql := NewBuilder().Update("a.user").
+		Cols("Name", "IsAdmin").
+		SetStructAsAssignments(&User{Name: "a", IsAdmin: true}).
+		Set("age = age + 1").
+		Equal("id", 1).
+		Build()
+	want := "UPDATE a.user SET name = 'a',is_admin = true,age = age + 1 WHERE (id = 1)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_SetStruct(t *testing.T) {
+	tests := []struct {
+		name string
+		cols []string
+		want string
+	}{
+		{"single column", []string{"Name"},
+			"UPDATE a.user SET name = 'a' WHERE (id = 1)"},
+		{"multiple columns", []string{"Name", "Age"},
+			"UPDATE a.user SET (name,age) = ('a',1) WHERE (id = 1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql := NewBuilder().Update("a.user").
+				Cols(tt.cols...).
+				SetStruct(User{Name: "a", Age: 1}).
+				Equal("id", 1).
+				Build()
+			if got := trimSQL(sql); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_QuoteIdentifiers(t *testing.T) {
+	sql := NewBuilder().Select("a.order").Alias("o").
+		QuoteIdentifiers().
+		Fields("o.status", "count(1)").
+		Equal("o.status", "paid").
+		GroupBy("o.status").
+		OrderBy("o.status DESC").
+		Build()
+	want := `SELECT "o"."status",count(1) FROM "a"."order" AS "o" WHERE ("o"."status" = 'paid') ` +
+		`GROUP BY "o"."status" ORDER BY "o"."status" DESC`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_QuoteIdentifiersExpression(t *testing.T) {
+	sql := NewBuilder().Select("a.order").
+		QuoteIdentifiers().
+		Fields("date_trunc('day', created_at)", "status", "count(1)").
+		GroupBy("date_trunc('day', created_at)", "status").
+		OrderBy("date_trunc('day', created_at) DESC", "2").
+		Build()
+	want := `SELECT date_trunc('day', created_at),"status",count(1) FROM "a"."order" ` +
+		`GROUP BY date_trunc('day', created_at),"status" ORDER BY date_trunc('day', created_at) DESC,2`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_FieldIf(t *testing.T) {
+	build := func(flag bool) string {
+		return trimSQL(NewBuilder().Select("a.user").
+			Fields("id").
+			FieldIf(flag, "remark").
+			FieldIf(!flag, "name").
+			Build())
+	}
+	if got, want := build(true), "SELECT id,remark FROM a.user"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if got, want := build(false), "SELECT id,name FROM a.user"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_InsertStruct(t *testing.T) {
+	sql := NewBuilder().Insert("a.user").
+		InsertStruct([]*User{{Name: "a", Age: 1}, {Name: "b", Age: 2}}, "Name", "Age").
+		Build()
+	want := "INSERT INTO a.user(name,age) VALUES ('a',1),('b',2)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("InsertStruct() with unknown column should panic")
+		}
+	}()
+	NewBuilder().Insert("a.user").InsertStruct(User{}, "Name", "Nickname")
+}
+
+func TestBuilder_Correlate(t *testing.T) {
+	orders := NewBuilder().Select("a.order").Alias("o").
+		Fields("1").
+		Correlate("u").
+		Where("o.user_id = u.id").
+		Equal("o.status", "paid")
+	sql := NewBuilder().Select("a.user").Alias("u").Exists(orders).Build()
+	want := "SELECT * FROM a.user AS u WHERE (EXISTS (SELECT 1 FROM a.order AS o " +
+		"WHERE (o.user_id = u.id) AND (o.status = 'paid')))"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	orders = NewBuilder().Select("a.order").Alias("o").
+		Fields("1").
+		Correlate("u").
+		CompareColumns("o.user_id", "=", "u.id").
+		QuoteIdentifiers()
+	sql = NewBuilder().Select("a.user").Alias("u").Exists(orders).Build()
+	want = `SELECT * FROM a.user AS u WHERE (EXISTS (SELECT 1 FROM "a"."order" AS "o" ` +
+		`WHERE ("o"."user_id" = "u"."id")))`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() with QuoteIdentifiers = %v, want %v", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Build() without correlation predicate should panic")
+		}
+	}()
+	NewBuilder().Select("a.order").Alias("o").Correlate("u").Equal("o.status", "paid").Build()
+}
+
+func TestBuilder_PartitionedUpsert(t *testing.T) {
+	users := []User{
+		{Id: 0, Name: "a", Age: 1},
+		{Id: 2, Name: "b", Age: 2},
+		{Id: 0, Name: "c", Age: 3},
+	}
+	insert, updates := NewBuilder().Insert("a.user").Cols("Name", "Age").
+		PartitionedUpsert(users, func(v interface{}) bool {
+			return v.(User).Id > 0
+		})
+	if got, want := trimSQL(insert), "INSERT INTO a.user(name,age) VALUES ('a',1),('c',3)"; got != want {
+		t.Errorf("PartitionedUpsert() insert = %v, want %v", got, want)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("PartitionedUpsert() updates = %v", updates)
+	}
+	if got, want := trimSQL(updates[0]), "UPDATE a.user SET (name,age) = ('b',2) WHERE (id = 2)"; got != want {
+		t.Errorf("PartitionedUpsert() update = %v, want %v", got, want)
+	}
+
+	_, updates = NewBuilder().Dialect(MySQL).QuoteIdentifiers().Insert("user").
+		Cols("Name").PrimaryKey("u.age").
+		PartitionedUpsert(users[1:2], func(v interface{}) bool { return true })
+	if len(updates) != 1 {
+		t.Fatalf("PartitionedUpsert() updates = %v", updates)
+	}
+	if got, want := trimSQL(updates[0]), "UPDATE `user` SET `name` = 'b' WHERE (`age` = 2)"; got != want {
+		t.Errorf("PartitionedUpsert() update = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_OrderByInOrder(t *testing.T) {
+	ids := []int64{3, 1, 2}
+	sql := NewBuilder().Select("a.user").In("id", ids).OrderByInOrder("id", ids).Build()
+	want := "SELECT * FROM a.user WHERE (id IN (3,1,2)) ORDER BY array_position(ARRAY[3,1,2], id)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql, args := NewBuilder().Select("a.user").In("id", ids).OrderByInOrder("id", ids).
+		Limit(10).QuoteIdentifiers().BuildArgs()
+	want = `SELECT * FROM "a"."user" WHERE ("id" IN ($1,$2,$3)) ` +
+		`ORDER BY array_position(ARRAY[$4,$5,$6], "id") LIMIT $7`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs := []interface{}{int64(3), int64(1), int64(2), int64(3), int64(1), int64(2), int64(10)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBuilder_BuildCount(t *testing.T) {
+	paid := NewBuilder().Select("a.order").Equal("status", "paid")
+	b := NewBuilder().With("paid", paid).
+		Select("a.user").Alias("u").
+		InnerJoin("paid", "p", "p.user_id = u.id").
+		Equal("u.is_admin", false)
+	want := "WITH paid AS (SELECT * FROM a.order WHERE (status = 'paid')) " +
+		"SELECT COUNT(1) FROM a.user AS u INNER JOIN paid AS p ON p.user_id = u.id " +
+		"WHERE (u.is_admin = false)"
+	if got := trimSQL(b.BuildCount()); got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+	want = "WITH paid AS (SELECT * FROM a.order WHERE (status = 'paid')) " +
+		"SELECT COUNT(DISTINCT u.id) FROM a.user AS u INNER JOIN paid AS p ON p.user_id = u.id " +
+		"WHERE (u.is_admin = false)"
+	if got := trimSQL(b.GroupBy("u.id").BuildCount()); got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+	want = `SELECT COUNT(DISTINCT "u"."order") FROM "a"."user" AS "u" WHERE ("u"."status" = 1)`
+	sql := NewBuilder().Select("a.user").Alias("u").GroupBy("u.order").Equal("u.status", 1).
+		QuoteIdentifiers().BuildCount()
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildCount() with QuoteIdentifiers = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_OrderByPosition(t *testing.T) {
+	b := NewBuilder().Select("a.order").
+		Fields("user_id, date_trunc('day', created_at)").FieldAs("count(1)", "n").
+		GroupBy("1,2").OrderByPosition(2).OrderByPositionDesc(3)
+	want := "SELECT user_id, date_trunc('day', created_at),count(1) AS n FROM a.order " +
+		"GROUP BY 1,2 ORDER BY 2,3 DESC"
+	if got := trimSQL(b.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("OrderByPosition() should panic on position out of range")
+		}
+	}()
+	b.OrderByPosition(4)
+}
+
+func TestBuilder_Schema(t *testing.T) {
+	tests := []struct {
+		b    *Builder
+		want string
+	}{
+		{NewBuilder().Schema("tenant_42").Select("user"),
+			"SELECT * FROM tenant_42.user"},
+		{NewBuilder().Schema("tenant_42").Select("public.config"),
+			"SELECT * FROM public.config"},
+		{NewBuilder().Schema("tenant_42").Select("user").Alias("u").
+			LeftJoin("order", "o", "o.user_id = u.id").
+			LeftJoin("public.region", "r", "r.id = u.region_id"),
+			"SELECT * FROM tenant_42.user AS u LEFT JOIN tenant_42.order AS o ON o.user_id = u.id " +
+				"LEFT JOIN public.region AS r ON r.id = u.region_id"},
+		{NewBuilder().Schema("tenant_42").SelectSubQuery("SELECT 1"),
+			"SELECT * FROM (SELECT 1)"},
+		{NewBuilder().Schema("tenant_42").Delete("user").Equal("id", 1),
+			"DELETE FROM tenant_42.user WHERE (id = 1)"},
+	}
+	for _, tt := range tests {
+		if got := trimSQL(tt.b.Build()); got != tt.want {
+			t.Errorf("Build() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestBuilder_DeleteArchiving(t *testing.T) {
+	b := NewBuilder().Delete("a.order").Equal("status", "expired")
+	want := "WITH d AS (DELETE FROM a.order WHERE (status = 'expired') RETURNING *) " +
+		"INSERT INTO a.order_archive SELECT * FROM d"
+	if got := trimSQL(b.DeleteArchiving("a.order_archive").Build()); got != want {
+		t.Errorf("DeleteArchiving() = %v, want %v", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("DeleteArchiving() should panic without condition")
+		}
+	}()
+	NewBuilder().Delete("a.order").DeleteArchiving("a.order_archive")
+}
+
+func TestBuilder_OnConflict(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"", "ON CONFLICT DO NOTHING"},
+		{"name", "ON CONFLICT (name) DO NOTHING"},
+		{"(name, tenant_id) WHERE deleted_at IS NULL",
+			"ON CONFLICT (name, tenant_id) WHERE deleted_at IS NULL DO NOTHING"},
+		{"ON CONSTRAINT user_name_key", "ON CONFLICT ON CONSTRAINT user_name_key DO NOTHING"},
+	}
+	for _, tt := range tests {
+		sql := NewBuilder().Insert("a.user").Cols("Name").
+			Values(User{Name: "a"}).
+			OnConflict(tt.target, "NOTHING").Build()
+		want := "INSERT INTO a.user(name) VALUES ('a') " + tt.want
+		if got := trimSQL(sql); got != want {
+			t.Errorf("Build() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBuilder_InTupleSubQuery(t *testing.T) {
+	sub := NewBuilder().Select("a.stock").Fields("sku", "warehouse_id").Equal("qty", 0)
+	sql, args := NewBuilder().Select("a.order_item").
+		Equal("order_id", 1).
+		InTupleSubQuery([]string{"sku", "warehouse_id"}, sub).
+		BuildArgs()
+	want := "SELECT * FROM a.order_item WHERE (order_id = $1) AND " +
+		"((sku,warehouse_id) IN (SELECT sku,warehouse_id FROM a.stock WHERE (qty = $2)))"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1, 0}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+}
+
+func TestBuilder_UpdateReturningOld(t *testing.T) {
+	sql := NewBuilder().Update("a.user").
+		Set("status = 'frozen'").
+		Equal("id", 1).
+		UpdateReturningOld("id", "status").
+		Build()
+	want := "WITH sqlolold AS (SELECT * FROM a.user WHERE (id = 1) FOR UPDATE)," +
+		"sqlolnew AS (UPDATE a.user SET status = 'frozen' WHERE (id = 1) RETURNING *) " +
+		"SELECT sqlolold.status AS old_status,sqlolnew.status AS new_status FROM sqlolold " +
+		"INNER JOIN sqlolnew ON sqlolold.id = sqlolnew.id"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("UpdateReturningOld() = %v, want %v", got, want)
+	}
+
+	sql = NewBuilder().Update("a.user").Alias("u").
+		Set("status = 'frozen'").
+		Equal("u.id", 1).
+		UpdateReturningOld("id", "status").
+		Build()
+	want = "WITH sqlolold AS (SELECT * FROM a.user AS u WHERE (u.id = 1) FOR UPDATE)," +
+		"sqlolnew AS (UPDATE a.user AS u SET status = 'frozen' WHERE (u.id = 1) RETURNING *) " +
+		"SELECT sqlolold.status AS old_status,sqlolnew.status AS new_status FROM sqlolold " +
+		"INNER JOIN sqlolnew ON sqlolold.id = sqlolnew.id"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("UpdateReturningOld() with alias = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_Distinct(t *testing.T) {
+	b := NewBuilder().Select("a.order").
+		DistinctOn("user_id", "status").
+		Fields("user_id", "status", "created_at").
+		OrderBy("user_id", "status", "created_at DESC")
+	want := "SELECT DISTINCT ON (user_id, status) user_id,status,created_at FROM a.order " +
+		"ORDER BY user_id,status,created_at DESC"
+	if got := trimSQL(b.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	want = "SELECT count(1) FROM (SELECT DISTINCT ON (user_id, status) user_id,status,created_at " +
+		"FROM a.order ) AS sqlolcount"
+	if got := trimSQL(b.BuildCount()); got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+	c := b.Clone()
+	b.Clear()
+	if got := trimSQL(b.Select("a.order").Distinct().Fields("status").Build()); got != "SELECT DISTINCT status FROM a.order" {
+		t.Errorf("Build() = %v", got)
+	}
+	if got := trimSQL(c.Build()); !strings.HasPrefix(got, "SELECT DISTINCT ON (user_id, status) ") {
+		t.Errorf("Clone().Build() = %v", got)
+	}
+}
+
+func TestBuilder_BuildCountPrimaryKey(t *testing.T) {
+	b := NewBuilder().Select("a.user").Alias("u").PrimaryKey("id").Equal("u.is_admin", false)
+	if got, want := trimSQL(b.BuildCount()), "SELECT COUNT(1) FROM a.user AS u WHERE (u.is_admin = false)"; got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+	b.LeftJoin("a.order", "o", "o.user_id = u.id")
+	want := "SELECT COUNT(DISTINCT u.id) FROM a.user AS u LEFT JOIN a.order AS o ON o.user_id = u.id " +
+		"WHERE (u.is_admin = false)"
+	if got := trimSQL(b.BuildCount()); got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+	sql := NewBuilder().Select("a.user").LeftJoin("a.order", "o", "o.user_id = a.user.id").BuildCount()
+	if got, want := trimSQL(sql), "SELECT COUNT(1) FROM a.user LEFT JOIN a.order AS o ON o.user_id = a.user.id"; got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_OrderByCoalesce(t *testing.T) {
+	sql := NewBuilder().Select("a.user").Alias("u").
+		LeftJoin("a.order", "o", "o.user_id = u.id").
+		OrderByCoalesce("o.paid_at", time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), true).
+		OrderByCoalesce("o.amount", 0, false).
+		Build()
+	want := "SELECT * FROM a.user AS u LEFT JOIN a.order AS o ON o.user_id = u.id " +
+		"ORDER BY COALESCE(o.paid_at, '1970-01-01T00:00:00Z') DESC,COALESCE(o.amount, 0)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql, args := NewBuilder().Select("a.order").Equal("status", "paid").
+		OrderByCoalesce("amount", 0, true).Limit(10).QuoteIdentifiers().BuildArgs()
+	want = `SELECT * FROM "a"."order" WHERE ("status" = $1) ORDER BY COALESCE("amount", $2) DESC LIMIT $3`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if wantArgs := []interface{}{"paid", 0, int64(10)}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBuilder_OnConflictDoUpdate(t *testing.T) {
+	sql := NewBuilder().Insert("a.user").Cols("Name", "Remark", "CreatedBy").
+		Values([]User{{Name: "a", Remark: "b", CreatedBy: 1}}).
+		OnConflictDoUpdate([]string{"Name"}).
+		Returning("id").
+		Build()
+	want := "INSERT INTO a.user(name,remark,created_by) VALUES ('a','b',1) " +
+		"ON CONFLICT (name) DO UPDATE SET remark = EXCLUDED.remark,created_by = EXCLUDED.created_by RETURNING id"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql = NewBuilder().Insert("a.user").Cols("Name", "Remark").
+		Values(User{Name: "a", Remark: "b"}).
+		OnConflictDoUpdate([]string{"name"}, "remark").
+		Build()
+	want = "INSERT INTO a.user(name,remark) VALUES ('a','b') " +
+		"ON CONFLICT (name) DO UPDATE SET remark = EXCLUDED.remark"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_AntiJoin(t *testing.T) {
+	sql := NewBuilder().Select("a.user").Alias("u").
+		AntiJoin("a.order", "o", "o.user_id = u.id").
+		Equal("u.is_admin", false).
+		Build()
+	want := "SELECT * FROM a.user AS u WHERE (NOT EXISTS (SELECT 1 FROM a.order AS o " +
+		"WHERE (o.user_id = u.id))) AND (u.is_admin = false)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_BuildBatch(t *testing.T) {
+	b := NewBuilder().Insert("a.user").Cols("Name").
+		Values([]User{{Name: "a"}, {Name: "b"}, {Name: "c"}}).
+		OnConflictDoNothing().
+		Returning("id")
+	var got []string
+	for _, sql := range b.BuildBatch(2) {
+		got = append(got, trimSQL(sql))
+	}
+	want := []string{
+		"INSERT INTO a.user(name) VALUES ('a'),('b') ON CONFLICT DO NOTHING RETURNING id",
+		"INSERT INTO a.user(name) VALUES ('c') ON CONFLICT DO NOTHING RETURNING id",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildBatch() = %v, want %v", got, want)
+	}
+	if got := b.BuildBatch(0); len(got) != 1 || got[0] != b.Build() {
+		t.Errorf("BuildBatch(0) = %v", got)
+	}
+}
+
+func TestBuilder_SemiJoin(t *testing.T) {
+	b := NewBuilder().Select("a.user").Alias("u").
+		Fields("u.id").
+		SemiJoin("a.order", "o", "o.user_id = u.id")
+	want := "SELECT u.id FROM a.user AS u WHERE (EXISTS (SELECT 1 FROM a.order AS o " +
+		"WHERE (o.user_id = u.id)))"
+	if got := trimSQL(b.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	// 子表只出现在WHERE中，不会使主表的行重复
+	if strings.Contains(b.Build(), "JOIN") {
+		t.Errorf("SemiJoin() should not join the child table")
+	}
+	if got, want := trimSQL(b.BuildCount()), "SELECT COUNT(1) FROM a.user AS u WHERE (EXISTS (SELECT 1 FROM a.order AS o "+
+		"WHERE (o.user_id = u.id)))"; got != want {
+		t.Errorf("BuildCount() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_CrossFullJoin(t *testing.T) {
+	sql := NewBuilder().Select("a.user").Alias("u").
+		CrossJoin("generate_series(1,3)", "g").
+		FullJoin("a.order", "o", "o.user_id = u.id").
+		Build()
+	want := "SELECT * FROM a.user AS u CROSS JOIN generate_series(1,3) AS g " +
+		"FULL JOIN a.order AS o ON o.user_id = u.id"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_FieldsPrefixed(t *testing.T) {
+	sql := NewBuilder().Select("a.user").Alias("u").
+		FieldsPrefixed("u", "id", "name", "*", "o.amount", "count(1)").
+		LeftJoin("a.order", "o", "o.user_id = u.id").
+		Build()
+	want := "SELECT u.id,u.name,u.*,o.amount,count(1) FROM a.user AS u LEFT JOIN a.order AS o ON o.user_id = u.id"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_FieldsFromStruct(t *testing.T) {
+	type base struct {
+		Id int64
+	}
+	type order struct {
+		base
+		UserId  int64
+		Amount  float64 `sql:"Total"`
+		private string
+	}
+	want := "SELECT id,user_id,total FROM a.order"
+	if got := trimSQL(NewBuilder().Select("a.order").FieldsFromStruct(&order{}).Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if got, want := CamelsToSnakes(StructExportedFields(order{})), []string{"id", "user_id", "total"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StructExportedFields() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_InSubQuery(t *testing.T) {
+	paid := NewBuilder().Select("a.order").Fields("user_id").Equal("status", "paid")
+	sql, args := NewBuilder().Select("a.user").
+		InSubQuery("id", "SELECT user_id FROM a.vip").
+		NotInSubQuery("id", "SELECT user_id FROM a.blacklist").
+		InSubBuilder("id", paid).
+		NotInSubBuilder("id", NewBuilder().Select("a.refund").Fields("user_id").Gt("amount", 100)).
+		BuildArgs()
+	want := "SELECT * FROM a.user WHERE (id IN (SELECT user_id FROM a.vip)) AND " +
+		"(id NOT IN (SELECT user_id FROM a.blacklist)) AND " +
+		"(id IN (SELECT user_id FROM a.order WHERE (status = $1))) AND " +
+		"(id NOT IN (SELECT user_id FROM a.refund WHERE (amount > $2)))"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"paid", 100}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+}
+
+func TestBuilder_OrderByAscDesc(t *testing.T) {
+	sql := NewBuilder().Select("a.user").
+		QuoteIdentifiers().
+		OrderByDesc("created_at").
+		OrderByNullsLast("u.score", "desc").
+		OrderByAsc("id", "name").
+		Build()
+	want := `SELECT * FROM "a"."user" ORDER BY "created_at" DESC,"u"."score" DESC NULLS LAST,"id" ASC,"name" ASC`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("OrderByNullsLast() should panic on invalid direction")
+		}
+	}()
+	NewBuilder().OrderByNullsLast("id", "DESC; DROP TABLE a")
+}
+
 type User struct {
 	Id        int64
 	Name      string
@@ -134,3 +866,452 @@ type User struct {
 	UpdatedBy int64      `json:"updatedBy,omitempty" sql:"default 0" comment:"更新者员工ID"`
 	UpdatedAt *time.Time `json:"updatedAt,omitempty" sql:"default null" comment:"更新时间"`
 }
+
+func TestBuilder_Paginate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{
+			"page 3",
+			NewBuilder().Select("users").OrderBy("id").Paginate(3, 20),
+			"SELECT * FROM users ORDER BY id LIMIT 20 OFFSET 40",
+		},
+		{
+			"first page",
+			NewBuilder().Select("users").Paginate(1, 20),
+			"SELECT * FROM users LIMIT 20",
+		},
+		{
+			"page clamped",
+			NewBuilder().Select("users").Paginate(-2, 20),
+			"SELECT * FROM users LIMIT 20",
+		},
+		{
+			"size ignored",
+			NewBuilder().Select("users").Paginate(2, 0),
+			"SELECT * FROM users",
+		},
+		{
+			"try no size",
+			NewBuilder().Select("users").TryPaginate(2, 0),
+			"SELECT * FROM users",
+		},
+		{
+			"try page 0",
+			NewBuilder().Select("users").TryPaginate(0, 20),
+			"SELECT * FROM users LIMIT 20",
+		},
+		{
+			"try page",
+			NewBuilder().Select("users").TryPaginate(2, 10),
+			"SELECT * FROM users LIMIT 10 OFFSET 10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimSQL(tt.builder.Build()); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_LockModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{
+			"for share",
+			NewBuilder().Select("a.user").Equal("id", 1).ForShare(),
+			"SELECT * FROM a.user WHERE (id = 1) FOR SHARE",
+		},
+		{
+			"skip locked",
+			NewBuilder().Select("a.job").Equal("status", "new").Limit(1).ForUpdate().SkipLocked(),
+			"SELECT * FROM a.job WHERE (status = 'new') LIMIT 1 FOR UPDATE SKIP LOCKED",
+		},
+		{
+			"nowait",
+			NewBuilder().Select("a.job").ForShare().NoWait(),
+			"SELECT * FROM a.job FOR SHARE NOWAIT",
+		},
+		{
+			"last mode wins",
+			NewBuilder().Select("a.job").ForShare().ForUpdate(),
+			"SELECT * FROM a.job FOR UPDATE",
+		},
+		{
+			"cleared",
+			func() *Builder {
+				b := NewBuilder().Select("a.job").ForUpdate().SkipLocked()
+				b.Clear()
+				return b.Select("a.job")
+			}(),
+			"SELECT * FROM a.job",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimSQL(tt.builder.Clone().Build()); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("SkipLocked().NoWait() expected panic")
+		}
+	}()
+	NewBuilder().Select("a.job").ForUpdate().SkipLocked().NoWait()
+}
+
+func TestBuilder_BuildCopy(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{
+			"cols",
+			NewBuilder().Insert("a.user").Cols("Name", "CreatedBy"),
+			"COPY a.user (name,created_by) FROM STDIN",
+		},
+		{
+			"values",
+			NewBuilder().Insert("a.user").Cols("Name", "Remark").Values([]User{{Name: "a"}}),
+			"COPY a.user (name,remark) FROM STDIN",
+		},
+		{
+			"schema",
+			NewBuilder().Schema("a").Insert("user").Cols("Name"),
+			"COPY a.user (name) FROM STDIN",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.builder.BuildCopy(); got != tt.want {
+				t.Errorf("BuildCopy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_SetExpr(t *testing.T) {
+	builder := NewBuilder().Update("a.user").
+		Increment("login_count", 1).
+		Decrement("credit", 2.5).
+		SetMap(map[string]interface{}{"name": "a'b"}).
+		SetExpr("remark", "concat(remark, ?, ?)", "-", "x").
+		Equal("id", 1)
+	want := "UPDATE a.user SET login_count = login_count + 1,credit = credit - 2.5,name = 'a''b'," +
+		"remark = concat(remark, '-', 'x') WHERE (id = 1)"
+	if got := trimSQL(builder.Clone().Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql, args := builder.BuildArgs()
+	want = "UPDATE a.user SET login_count = login_count + $1,credit = credit - $2,name = $3," +
+		"remark = concat(remark, $4, $5) WHERE (id = $6)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	wantArgs := []interface{}{1, 2.5, "a'b", "-", "x", 1}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+	sql, args = NewBuilder().Update("a.user").
+		SetExpr("remark", "CASE WHEN tags ?? 'vip' THEN 'why?' ELSE ? END", "x").
+		Equal("id", 1).
+		BuildArgs()
+	want = "UPDATE a.user SET remark = CASE WHEN tags ? 'vip' THEN 'why?' ELSE $1 END WHERE (id = $2)"
+	if got := trimSQL(sql); got != want || len(args) != 2 {
+		t.Errorf("BuildArgs() = %v %v, want %v", got, args, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("SetExpr() expected panic on args mismatch")
+		}
+	}()
+	NewBuilder().Update("a.user").SetExpr("age", "age + ?")
+}
+
+func TestBuilder_ReverseOrder(t *testing.T) {
+	sql := NewBuilder().Select("a.user").
+		OrderBy("age desc", "name, remark ASC NULLS FIRST").
+		OrderByNullsLast("score", "asc").
+		OrderBy("date_trunc('day', created_at)").
+		ReverseOrder().
+		Build()
+	want := "SELECT * FROM a.user ORDER BY age ASC,name DESC,remark DESC NULLS LAST," +
+		"score DESC NULLS FIRST,date_trunc('day', created_at) DESC"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql = NewBuilder().Select("a.user").OrderByDesc("id").NullsOrder(true).
+		ReverseOrder().ReverseOrder().
+		Build()
+	want = "SELECT * FROM a.user ORDER BY id DESC NULLS LAST"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_QuoteIdentifiersTables(t *testing.T) {
+	sql := NewBuilder().QuoteIdentifiers().Select("order").Alias("o").
+		Fields("o.select", "UserName").
+		LeftJoin("a.User", "u", `u.id = o.user_id`).
+		Equal("o.select", 1).
+		Build()
+	want := `SELECT "o"."select","UserName" FROM "order" AS "o" ` +
+		`LEFT JOIN "a"."User" AS "u" ON u.id = o.user_id WHERE ("o"."select" = 1)`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql = NewBuilder().QuoteIdentifiers().Insert("Order").Cols("Name", "Remark").
+		Values(User{Name: "a", Remark: "b"}).
+		Build()
+	want = `INSERT INTO "Order"("name","remark") VALUES ('a','b')`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if got, want := Quote(`we"ird`), `"we""ird"`; got != want {
+		t.Errorf("Quote() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_QuoteIdentifiersStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{
+			"conditions before QuoteIdentifiers",
+			NewBuilder().Select("order").Equal("user", 1).QuoteIdentifiers().Dialect(MySQL),
+			"SELECT * FROM `order` WHERE (`user` = 1)",
+		},
+		{
+			"distinct on",
+			NewBuilder().QuoteIdentifiers().Select("a.user").DistinctOn("order").Fields("order"),
+			`SELECT DISTINCT ON ("order") "order" FROM "a"."user"`,
+		},
+		{
+			"update assignments",
+			NewBuilder().Update("a.user").SetMap(map[string]interface{}{"order": 1}).
+				Increment("limit", 1).Equal("id", 1).Returning("order").QuoteIdentifiers(),
+			`UPDATE "a"."user" SET "order" = 1,"limit" = "limit" + 1 WHERE ("id" = 1) RETURNING "order"`,
+		},
+		{
+			"single column struct",
+			NewBuilder().QuoteIdentifiers().Update("a.user").Cols("Name").SetStruct(User{Name: "a"}).Equal("id", 1),
+			`UPDATE "a"."user" SET "name" = 'a' WHERE ("id" = 1)`,
+		},
+		{
+			"on conflict",
+			NewBuilder().QuoteIdentifiers().Insert("a.user").Cols("Name", "Remark").
+				Values(User{Name: "a", Remark: "b"}).
+				OnConflictDoUpdate([]string{"name"}).OnConflictPreserve("remark"),
+			`INSERT INTO "a"."user"("name","remark") VALUES ('a','b') ` +
+				`ON CONFLICT ("name") DO UPDATE SET "remark" = COALESCE(EXCLUDED."remark", "a"."user"."remark")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimSQL(tt.builder.Build()); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_LimitPercent(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+	}{
+		{
+			"percent",
+			NewBuilder().Dialect(fetchDialect{}).Select("a.user").OrderBy("score DESC").LimitPercent(10),
+			"SELECT * FROM a.user ORDER BY score DESC FETCH FIRST 10 PERCENT ROWS ONLY",
+		},
+		{
+			"offset",
+			NewBuilder().Dialect(fetchDialect{}).Select("a.user").OrderBy("id").LimitPercent(2.5).Offset(5),
+			"SELECT * FROM a.user ORDER BY id OFFSET 5 ROWS FETCH FIRST 2.5 PERCENT ROWS ONLY",
+		},
+		{
+			"limit wins",
+			NewBuilder().Dialect(fetchDialect{}).Select("a.user").LimitPercent(10).Limit(5),
+			"SELECT * FROM a.user LIMIT 5",
+		},
+		{
+			"percent wins",
+			NewBuilder().Dialect(fetchDialect{}).Select("a.user").Limit(5).LimitPercent(50),
+			"SELECT * FROM a.user FETCH FIRST 50 PERCENT ROWS ONLY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimSQL(tt.builder.Build()); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, d := range []Dialect{Postgres, MySQL} {
+		_, err := NewBuilder().Dialect(d).Select("a.user").LimitPercent(10).BuildE()
+		if !errors.Is(err, errPercentDialect) {
+			t.Errorf("BuildE() error = %v, want %v", err, errPercentDialect)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("LimitPercent() expected panic on out of range percent")
+		}
+	}()
+	NewBuilder().Select("a.user").LimitPercent(101)
+}
+
+// 支持 FETCH FIRST n PERCENT 的方言，如Oracle、DB2
+type fetchDialect struct {
+	postgres
+}
+
+func (fetchDialect) LimitPercent(percent float64, offset int64) string {
+	sql := "FETCH FIRST " + strconv.FormatFloat(percent, 'f', -1, 64) + " PERCENT ROWS ONLY"
+	if offset > 0 {
+		sql = "OFFSET " + strconv.FormatInt(offset, 10) + " ROWS " + sql
+	}
+	return sql
+}
+
+func TestBuilder_Having(t *testing.T) {
+	builder := NewBuilder().Select("a.order").
+		Fields("user_id", "COUNT(1)").
+		GroupBy("user_id").
+		Having("SUM(amount) > 100").
+		HavingGt("COUNT(1)", 5).
+		HavingEqual("MAX(status)", "paid")
+	want := "SELECT user_id,COUNT(1) FROM a.order GROUP BY user_id " +
+		"HAVING (SUM(amount) > 100) AND (COUNT(1) > 5) AND (MAX(status) = 'paid')"
+	if got := trimSQL(builder.Clone().Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.HavingBuilder.Lte("MIN(amount)", 10)
+	sql, args := builder.Equal("user_id", 3).BuildArgs()
+	want = "SELECT user_id,COUNT(1) FROM a.order WHERE (user_id = $1) GROUP BY user_id " +
+		"HAVING (SUM(amount) > 100) AND (COUNT(1) > $2) AND (MAX(status) = $3) AND (MIN(amount) <= $4)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if wantArgs := []interface{}{3, 5, "paid", 10}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("BuildArgs() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBuilder_InsertSelect(t *testing.T) {
+	sub := NewBuilder().Select("a.user").Fields("name", "remark").Equal("status", "new")
+	builder := NewBuilder().InsertSelect("a.user_archive", []string{"Name", "Remark"}, sub).
+		OnConflictDoNothing().
+		Returning("id")
+	want := "INSERT INTO a.user_archive(name,remark) SELECT name,remark FROM a.user WHERE (status = 'new') " +
+		"ON CONFLICT DO NOTHING RETURNING id"
+	if got := trimSQL(builder.Clone().Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql, args := builder.BuildArgs()
+	if !strings.Contains(sql, "WHERE (status = $1)") || !reflect.DeepEqual(args, []interface{}{"new"}) {
+		t.Errorf("BuildArgs() = %v, %v", sql, args)
+	}
+	for _, b := range []*Builder{
+		NewBuilder().Insert("a.user_archive"),
+		NewBuilder().InsertSelect("a.user_archive", nil, nil),
+	} {
+		if _, err := b.BuildE(); !errors.Is(err, ErrNoValues) {
+			t.Errorf("BuildE() error = %v, want %v", err, ErrNoValues)
+		}
+	}
+}
+
+func TestBuilder_AllowEmptyInsert(t *testing.T) {
+	if _, err := NewBuilder().Insert("a.user").Values([]User{}).BuildE(); !errors.Is(err, ErrNoValues) {
+		t.Errorf("BuildE() error = %v, want %v", err, ErrNoValues)
+	}
+	builder := NewBuilder().Insert("a.user").Values([]User{}).AllowEmptyInsert().
+		With("t", NewBuilder().Select("a.tmp").Equal("id", 1)).
+		Returning("id")
+	if got := builder.Build(); got != "" {
+		t.Errorf("Build() = %v, want empty", got)
+	}
+	if sql, args := builder.BuildArgs(); sql != "" || args != nil {
+		t.Errorf("BuildArgs() = %v, %v, want empty", sql, args)
+	}
+	if got := builder.BuildBatch(10); got != nil {
+		t.Errorf("BuildBatch() = %v, want nil", got)
+	}
+	if errs := builder.Errors(); len(errs) != 0 {
+		t.Errorf("Errors() = %v, want none", errs)
+	}
+}
+
+func TestBuilder_ReturningStruct(t *testing.T) {
+	type Order struct {
+		Id        int64
+		UserId    int64
+		CreatedAt time.Time
+	}
+	sql := NewBuilder().Insert("a.order").Cols("UserId").Values(Order{UserId: 1}).
+		ReturningStruct(&Order{}).
+		Build()
+	want := "INSERT INTO a.order(user_id) VALUES (1) RETURNING id,user_id,created_at"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql = NewBuilder().Delete("a.user").Equal("id", 1).ReturningAll().Build()
+	if got, want := trimSQL(sql), "DELETE FROM a.user WHERE (id = 1) RETURNING *"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_OnConflictPreserve(t *testing.T) {
+	builder := NewBuilder().Insert("a.user").Cols("Name", "Remark", "CreatedBy").
+		Values(User{Name: "a", CreatedBy: 1}).
+		OnConflictDoUpdate([]string{"Name"})
+	plain := builder.Clone()
+	builder.OnConflictPreserve("Remark")
+	want := "INSERT INTO a.user(name,remark,created_by) VALUES ('a','',1) ON CONFLICT (name) DO UPDATE " +
+		"SET remark = COALESCE(EXCLUDED.remark, a.user.remark),created_by = EXCLUDED.created_by"
+	if got := trimSQL(builder.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	want = "INSERT INTO a.user(name,remark,created_by) VALUES ('a','',1) ON CONFLICT (name) DO UPDATE " +
+		"SET remark = EXCLUDED.remark,created_by = EXCLUDED.created_by"
+	if got := trimSQL(plain.Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	sql := NewBuilder().Insert("a.user").Alias("u").Cols("Name", "Remark").
+		Values(User{Name: "a"}).
+		OnConflictDoUpdate([]string{"name"}, "remark").
+		OnConflictPreserve("remark").
+		Build()
+	want = "INSERT INTO a.user AS u(name,remark) VALUES ('a','') ON CONFLICT (name) DO UPDATE " +
+		"SET remark = COALESCE(EXCLUDED.remark, u.remark)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	sql = NewBuilder().Dialect(MySQL).Insert("user").Cols("Name", "Remark").
+		Values(User{Name: "a"}).
+		OnConflictDoUpdate([]string{"name"}).
+		OnConflictPreserve("Remark").
+		Build()
+	want = "INSERT INTO user(name,remark) VALUES ('a','') ON DUPLICATE KEY UPDATE " +
+		"remark = COALESCE(VALUES(remark), user.remark)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
@@ -2,35 +2,244 @@ package sqlol
 
 import (
 	"fmt"
+	"log"
+	"reflect"
 	"strings"
 	"time"
 )
 
 type ConditionBuilder struct {
-	wheres []string
+	wheres           []string
+	args             []interface{}
+	combinator       string
+	groups           [][]string
+	quoteIdentifiers bool
+	dedupe           bool
+	dialect          Dialect
+	searchMode       SearchMode
 }
 
 // 生成最终的sql
 func (b *ConditionBuilder) Build() string {
-	return strings.TrimSpace(strings.Join(b.wheres, " AND "))
+	sql, args := b.build()
+	return inlineArgs(b.resolveIdents(sql), args, b.dialect)
+}
+
+// 生成整体加括号的条件，如 ((a = 1) AND (b = 2))，便于拼接到其它sql中，没有条件时返回空字符串
+func (b *ConditionBuilder) BuildGrouped() string {
+	sql := b.Build()
+	if sql == "" {
+		return ""
+	}
+	wheres, _ := b.conditions()
+	return parenthesize(wheres, sql)
+}
+
+// 生成带参数标记的sql及对应的参数
+func (b *ConditionBuilder) build() (string, []interface{}) {
+	if len(b.groups) > 0 {
+		log.Panic("sqlol: condition group is not ended")
+	}
+	combinator := b.combinator
+	if combinator == "" {
+		combinator = "AND"
+	}
+	wheres, args := b.conditions()
+	return strings.TrimSpace(strings.Join(wheres, " "+combinator+" ")), args
+}
+
+// 返回顶层条件及其参数，开启DedupeConditions时去掉完全相同的条件(sql与参数值都相同)，保留第一次出现的位置
+func (b *ConditionBuilder) conditions() ([]string, []interface{}) {
+	if !b.dedupe {
+		return b.wheres, b.args
+	}
+	var wheres []string
+	var args []interface{}
+	seen := make(map[string]bool)
+	rest := b.args
+	for _, where := range b.wheres {
+		n := strings.Count(where, argMarker)
+		whereArgs := rest[:n]
+		rest = rest[n:]
+		key := inlineArgs(where, whereArgs, nil)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		wheres = append(wheres, where)
+		args = append(args, whereArgs...)
+	}
+	return wheres, args
+}
+
+// 生成sql时去掉重复的顶层条件，如多层中间件重复添加的 (status = 'active')
+func (b *ConditionBuilder) DedupeConditions() *ConditionBuilder {
+	b.dedupe = true
+	return b
 }
 
 // 清空
 func (b *ConditionBuilder) Clear() {
 	b.wheres = nil
+	b.args = nil
+	b.combinator = ""
+	b.groups = nil
+	b.quoteIdentifiers = false
+	b.dedupe = false
+	b.dialect = nil
+	b.searchMode = ""
+}
+
+// 设置顶层条件之间的连接方式(AND/OR)，默认为AND，
+// 每个条件都已用括号包裹，不会相互影响优先级
+func (b *ConditionBuilder) Combinator(op string) *ConditionBuilder {
+	op = strings.ToUpper(strings.TrimSpace(op))
+	if op != "AND" && op != "OR" {
+		log.Panic("sqlol: combinator must be AND or OR")
+	}
+	b.combinator = op
+	return b
+}
+
+func (b *ConditionBuilder) clone() ConditionBuilder {
+	var groups [][]string
+	for _, group := range b.groups {
+		groups = append(groups, copyStringSlice(group))
+	}
+	return ConditionBuilder{
+		wheres:           copyStringSlice(b.wheres),
+		args:             append([]interface{}(nil), b.args...),
+		combinator:       b.combinator,
+		groups:           groups,
+		quoteIdentifiers: b.quoteIdentifiers,
+		dedupe:           b.dedupe,
+		dialect:          b.dialect,
+		searchMode:       b.searchMode,
+	}
+}
+
+// 开启标识符转义，条件中的字段名会用双引号包裹，如 o.status => "o"."status"，
+// 在生成sql时转义，与调用顺序无关，Where/Or中的原始sql不会被转义
+func (b *ConditionBuilder) QuoteIdentifiers() *ConditionBuilder {
+	b.quoteIdentifiers = true
+	return b
+}
+
+func (b *ConditionBuilder) ident(name string) string {
+	return markIdent(name)
+}
+
+// 将标识符标记替换为标识符，开启QuoteIdentifiers时按方言转义
+func (b *ConditionBuilder) resolveIdents(sql string) string {
+	return resolveIdents(sql, b.quoteIdentifiers, b.dialect)
+}
+
+// 开始一个条件分组，之后添加的条件会暂存，直到EndGroup时用AND连接并整体加括号，
+// 分组可以嵌套
+func (b *ConditionBuilder) BeginGroup() *ConditionBuilder {
+	b.groups = append(b.groups, []string{})
+	return b
+}
+
+// 结束最近一个条件分组
+func (b *ConditionBuilder) EndGroup() *ConditionBuilder {
+	n := len(b.groups)
+	if n == 0 {
+		log.Panic("sqlol: EndGroup without BeginGroup")
+	}
+	group := b.groups[n-1]
+	b.groups = b.groups[:n-1]
+	if len(group) > 0 {
+		b.Where(strings.Join(group, " AND "))
+	}
+	return b
+}
+
+// 在新的ConditionBuilder中通过fn添加条件，整体加括号后作为一个AND条件添加，
+// fn中可以继续嵌套WhereGroup/OrGroup，没有添加条件时跳过
+func (b *ConditionBuilder) WhereGroup(fn func(cb *ConditionBuilder)) *ConditionBuilder {
+	sub := ConditionBuilder{quoteIdentifiers: b.quoteIdentifiers, dialect: b.dialect}
+	fn(&sub)
+	if condition, args := sub.build(); condition != "" {
+		b.append(parenthesize(sub.wheres, condition))
+		b.args = append(b.args, args...)
+	}
+	return b
+}
+
+// 与WhereGroup相同，但与之前已添加的(同一分组中的)所有条件以OR连接，
+// 如 WhereGroup(a AND b).OrGroup(c) => ((a AND b) OR c)
+func (b *ConditionBuilder) OrGroup(fn func(cb *ConditionBuilder)) *ConditionBuilder {
+	sub := ConditionBuilder{quoteIdentifiers: b.quoteIdentifiers, dialect: b.dialect}
+	fn(&sub)
+	condition, args := sub.build()
+	if condition == "" {
+		return b
+	}
+	condition = parenthesize(sub.wheres, condition)
+	var previous []string
+	combinator := "AND"
+	if n := len(b.groups); n > 0 {
+		previous, b.groups[n-1] = b.groups[n-1], nil
+	} else {
+		previous, b.wheres = b.wheres, nil
+		if b.combinator != "" {
+			combinator = b.combinator
+		}
+	}
+	if len(previous) > 0 {
+		condition = fmt.Sprintf("(%s OR %s)",
+			parenthesize(previous, strings.Join(previous, " "+combinator+" ")), condition)
+	}
+	b.append(condition)
+	b.args = append(b.args, args...)
+	return b
+}
+
+// wheres中的每个条件都已加括号，多个条件连接后需要再加一层括号
+func parenthesize(wheres []string, joined string) string {
+	if len(wheres) == 1 {
+		return joined
+	}
+	return "(" + joined + ")"
+}
+
+func (b *ConditionBuilder) append(condition string) {
+	if n := len(b.groups); n > 0 {
+		b.groups[n-1] = append(b.groups[n-1], condition)
+	} else {
+		b.wheres = append(b.wheres, condition)
+	}
 }
 
 // 添加多个查询AND条件
 func (b *ConditionBuilder) Where(strs ...string) *ConditionBuilder {
 	for _, str := range strs {
 		if str != "" {
-			// tip: 括号包裹条件，防止条件之间相互影响优先级
-			b.wheres = append(b.wheres, "("+str+")")
+			b.where(str)
 		}
 	}
 	return b
 }
 
+// 添加一个条件，condition中的参数标记与args按顺序对应
+func (b *ConditionBuilder) where(condition string, args ...interface{}) *ConditionBuilder {
+	// tip: 括号包裹条件，防止条件之间相互影响优先级
+	b.append("(" + condition + ")")
+	b.args = append(b.args, args...)
+	return b
+}
+
+// 添加恒为假的条件(1=0)，使查询不返回任何行
+func (b *ConditionBuilder) WhereFalse() *ConditionBuilder {
+	return b.Where("1=0")
+}
+
+// 添加恒为真的条件(1=1)，显式表示不做过滤
+func (b *ConditionBuilder) WhereTrue() *ConditionBuilder {
+	return b.Where("1=1")
+}
+
 func (b *ConditionBuilder) WhereMap(where map[string]interface{}) *ConditionBuilder {
 	for k, v := range where {
 		b.Equal(k, v)
@@ -62,9 +271,48 @@ func (b *ConditionBuilder) Or(strs ...string) *ConditionBuilder {
 // 添加相等条件
 func (b *ConditionBuilder) Equal(dbField string, value interface{}) *ConditionBuilder {
 	if value == nil {
-		return b.Where(fmt.Sprintf("%s IS NULL", dbField))
+		return b.Where(fmt.Sprintf("%s IS NULL", b.ident(dbField)))
+	}
+	return b.where(fmt.Sprintf("%s = %s", b.ident(dbField), argMarker), value)
+}
+
+// 为每个字段添加与value的相等条件，以AND连接
+func (b *ConditionBuilder) EqualAll(dbFields []string, value interface{}) *ConditionBuilder {
+	for _, dbField := range dbFields {
+		b.Equal(dbField, value)
+	}
+	return b
+}
+
+// 添加任一字段与value相等的条件，如 (a = 1 OR b = 1)，value为nil时为 IS NULL
+func (b *ConditionBuilder) EqualAny(dbFields []string, value interface{}) *ConditionBuilder {
+	if len(dbFields) == 0 {
+		return b
+	}
+	conditions := make([]string, len(dbFields))
+	var args []interface{}
+	for i, dbField := range dbFields {
+		if value == nil {
+			conditions[i] = fmt.Sprintf("%s IS NULL", b.ident(dbField))
+		} else {
+			conditions[i] = fmt.Sprintf("%s = %s", b.ident(dbField), argMarker)
+			args = append(args, value)
+		}
+	}
+	return b.where(strings.Join(conditions, " OR "), args...)
+}
+
+// 添加带类型转换的相等条件，如 id = $1::uuid，用于参数类型有歧义时，value为nil时为 IS NULL
+func (b *ConditionBuilder) EqualTyped(dbField, sqlType string, value interface{}) *ConditionBuilder {
+	if value == nil {
+		return b.Equal(dbField, nil)
 	}
-	return b.Where(fmt.Sprintf("%s = %s", dbField, ToString(value)))
+	return b.where(fmt.Sprintf("%s = %s::%s", b.ident(dbField), argMarker, sqlType), value)
+}
+
+// 添加枚举类型字段的相等条件，值会转换为enumType，如 status = 'paid'::order_status
+func (b *ConditionBuilder) EqualEnum(dbField, enumType string, value interface{}) *ConditionBuilder {
+	return b.EqualTyped(dbField, enumType, value)
 }
 
 // 添加相等条件，value为零值时跳过
@@ -75,10 +323,113 @@ func (b *ConditionBuilder) TryEqual(dbField string, value interface{}) *Conditio
 	return b.Equal(dbField, value)
 }
 
+// 添加不等条件，value为nil时为 IS NOT NULL
+func (b *ConditionBuilder) NotEqual(dbField string, value interface{}) *ConditionBuilder {
+	if value == nil {
+		return b.IsNotNull(dbField)
+	}
+	return b.compare(dbField, "<>", value)
+}
+
+// 添加不等条件，value为零值时跳过
+func (b *ConditionBuilder) TryNotEqual(dbField string, value interface{}) *ConditionBuilder {
+	if isEmpty(value) {
+		return b
+	}
+	return b.NotEqual(dbField, value)
+}
+
+// 添加大于条件
+func (b *ConditionBuilder) Gt(dbField string, value interface{}) *ConditionBuilder {
+	return b.compare(dbField, ">", value)
+}
+
+// 添加大于条件，value为零值时跳过
+func (b *ConditionBuilder) TryGt(dbField string, value interface{}) *ConditionBuilder {
+	if isEmpty(value) {
+		return b
+	}
+	return b.Gt(dbField, value)
+}
+
+// 添加大于等于条件
+func (b *ConditionBuilder) Gte(dbField string, value interface{}) *ConditionBuilder {
+	return b.compare(dbField, ">=", value)
+}
+
+// 添加大于等于条件，value为零值时跳过
+func (b *ConditionBuilder) TryGte(dbField string, value interface{}) *ConditionBuilder {
+	if isEmpty(value) {
+		return b
+	}
+	return b.Gte(dbField, value)
+}
+
+// 添加小于条件
+func (b *ConditionBuilder) Lt(dbField string, value interface{}) *ConditionBuilder {
+	return b.compare(dbField, "<", value)
+}
+
+// 添加小于条件，value为零值时跳过
+func (b *ConditionBuilder) TryLt(dbField string, value interface{}) *ConditionBuilder {
+	if isEmpty(value) {
+		return b
+	}
+	return b.Lt(dbField, value)
+}
+
+// 添加小于等于条件
+func (b *ConditionBuilder) Lte(dbField string, value interface{}) *ConditionBuilder {
+	return b.compare(dbField, "<=", value)
+}
+
+// 添加小于等于条件，value为零值时跳过
+func (b *ConditionBuilder) TryLte(dbField string, value interface{}) *ConditionBuilder {
+	if isEmpty(value) {
+		return b
+	}
+	return b.Lte(dbField, value)
+}
+
+func (b *ConditionBuilder) compare(dbField, op string, value interface{}) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s %s %s", b.ident(dbField), op, argMarker), value)
+}
+
+var columnCompareOps = []string{"=", "<>", "!=", ">", ">=", "<", "<=", "IS DISTINCT FROM", "IS NOT DISTINCT FROM"}
+
+// 比较两个字段，如 CompareColumns("updated_at", ">", "created_at")，两侧均作为字段或表达式，不作为值转义
+func (b *ConditionBuilder) CompareColumns(left, op, right string) *ConditionBuilder {
+	op = strings.ToUpper(strings.TrimSpace(op))
+	if !containsString(columnCompareOps, op) {
+		log.Panicf("sqlol: unsupported compare operator %q", op)
+	}
+	return b.Where(fmt.Sprintf("%s %s %s", b.ident(left), op, b.ident(right)))
+}
+
+// 添加IS NULL条件
+func (b *ConditionBuilder) IsNull(dbField string) *ConditionBuilder {
+	return b.Where(fmt.Sprintf("%s IS NULL", b.ident(dbField)))
+}
+
+// 添加IS NOT NULL条件
+func (b *ConditionBuilder) IsNotNull(dbField string) *ConditionBuilder {
+	return b.Where(fmt.Sprintf("%s IS NOT NULL", b.ident(dbField)))
+}
+
+// 添加IS TRUE条件，对可为NULL的布尔字段，NULL不会匹配
+func (b *ConditionBuilder) IsTrue(dbField string) *ConditionBuilder {
+	return b.Where(fmt.Sprintf("%s IS TRUE", b.ident(dbField)))
+}
+
+// 添加IS FALSE条件，对可为NULL的布尔字段，NULL不会匹配
+func (b *ConditionBuilder) IsFalse(dbField string) *ConditionBuilder {
+	return b.Where(fmt.Sprintf("%s IS FALSE", b.ident(dbField)))
+}
+
 // 添加LIKE条件，左右模糊匹配，
 // 如果需要单边模糊匹配，请使用Where
 func (b *ConditionBuilder) Like(dbField, value string) *ConditionBuilder {
-	return b.Where(fmt.Sprintf("%s LIKE %s", dbField, String("%"+value+"%")))
+	return b.like(dbField, "LIKE", value)
 }
 
 // 添加LIKE条件，左右模糊匹配，value为零值时跳过
@@ -89,14 +440,39 @@ func (b *ConditionBuilder) TryLike(dbField string, value string) *ConditionBuild
 	return b
 }
 
+// 添加ILIKE条件，不区分大小写，左右模糊匹配
+func (b *ConditionBuilder) ILike(dbField, value string) *ConditionBuilder {
+	return b.like(dbField, "ILIKE", value)
+}
+
+// 添加ILIKE条件，value为零值时跳过
+func (b *ConditionBuilder) TryILike(dbField string, value string) *ConditionBuilder {
+	if value := strings.TrimSpace(value); value != "" {
+		return b.ILike(dbField, value)
+	}
+	return b
+}
+
+// 添加NOT LIKE条件，左右模糊匹配
+func (b *ConditionBuilder) NotLike(dbField, value string) *ConditionBuilder {
+	return b.like(dbField, "NOT LIKE", value)
+}
+
+// 添加NOT LIKE条件，value为零值时跳过
+func (b *ConditionBuilder) TryNotLike(dbField string, value string) *ConditionBuilder {
+	if value := strings.TrimSpace(value); value != "" {
+		return b.NotLike(dbField, value)
+	}
+	return b
+}
+
+func (b *ConditionBuilder) like(dbField, op, value string) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s %s %s", b.ident(dbField), op, argMarker), "%"+value+"%")
+}
+
 // 添加多个LIKE条件
 func (b *ConditionBuilder) MultiLike(dbFields []string, value string) *ConditionBuilder {
-	v := String("%" + value + "%")
-	var cons []string
-	for _, field := range dbFields {
-		cons = append(cons, fmt.Sprintf("%s LIKE %s", field, v))
-	}
-	return b.Or(cons...)
+	return b.multiLike(dbFields, "LIKE", value)
 }
 
 // 添加多个LIKE条件，value为零值时跳过
@@ -107,32 +483,225 @@ func (b *ConditionBuilder) TryMultiLike(dbFields []string, value string) *Condit
 	return b
 }
 
+// 添加多个ILIKE条件
+func (b *ConditionBuilder) MultiILike(dbFields []string, value string) *ConditionBuilder {
+	return b.multiLike(dbFields, "ILIKE", value)
+}
+
+// 添加多个ILIKE条件，value为零值时跳过
+func (b *ConditionBuilder) TryMultiILike(dbFields []string, value string) *ConditionBuilder {
+	if v := strings.TrimSpace(value); v != "" {
+		return b.MultiILike(dbFields, v)
+	}
+	return b
+}
+
+func (b *ConditionBuilder) multiLike(dbFields []string, op, value string) *ConditionBuilder {
+	return b.multiMatch(dbFields, func(field string) string {
+		return field + " " + op + " " + argMarker
+	}, "%"+value+"%")
+}
+
+// 对每个字段生成一个条件并用OR连接，condition返回的条件中含一个参数标记，对应arg
+func (b *ConditionBuilder) multiMatch(dbFields []string, condition func(field string) string,
+	arg interface{}) *ConditionBuilder {
+	if len(dbFields) == 0 {
+		return b
+	}
+	cons := make([]string, len(dbFields))
+	args := make([]interface{}, len(dbFields))
+	for i, field := range dbFields {
+		cons[i] = "(" + condition(b.ident(field)) + ")"
+		args[i] = arg
+	}
+	return b.where(strings.Join(cons, " OR "), args...)
+}
+
+// MultiSearch使用的匹配方式
+type SearchMode string
+
+const (
+	// field ILIKE '%value%'，默认
+	SearchILike SearchMode = "ILIKE"
+	// LOWER(field) LIKE LOWER('%value%')，可以使用 LOWER(field) 上的表达式索引
+	SearchLower SearchMode = "LOWER"
+	// field % 'value'，pg_trgm的相似度匹配，需要安装pg_trgm扩展
+	SearchTrigram SearchMode = "TRIGRAM"
+)
+
+// 设置MultiSearch的匹配方式，未设置时为SearchILike
+func (b *ConditionBuilder) SearchMode(mode SearchMode) *ConditionBuilder {
+	switch mode {
+	case SearchILike, SearchLower, SearchTrigram:
+	default:
+		log.Panicf("sqlol: unsupported search mode %q", mode)
+	}
+	b.searchMode = mode
+	return b
+}
+
+// 在多个字段中不区分大小写地搜索value，任一字段匹配即可，匹配方式由SearchMode决定
+func (b *ConditionBuilder) MultiSearch(dbFields []string, value string) *ConditionBuilder {
+	switch b.searchMode {
+	case SearchLower:
+		return b.multiMatch(dbFields, func(field string) string {
+			return "LOWER(" + field + ") LIKE LOWER(" + argMarker + ")"
+		}, "%"+value+"%")
+	case SearchTrigram:
+		return b.multiMatch(dbFields, func(field string) string {
+			return field + " % " + argMarker
+		}, value)
+	default:
+		return b.MultiILike(dbFields, value)
+	}
+}
+
+// 多字段搜索，value为零值时跳过
+func (b *ConditionBuilder) TryMultiSearch(dbFields []string, value string) *ConditionBuilder {
+	if v := strings.TrimSpace(value); v != "" {
+		return b.MultiSearch(dbFields, v)
+	}
+	return b
+}
+
+// 添加正则匹配条件(~)，区分大小写
+func (b *ConditionBuilder) Regex(dbField, pattern string) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s ~ %s", b.ident(dbField), argMarker), pattern)
+}
+
+// 添加正则匹配条件(~*)，不区分大小写
+func (b *ConditionBuilder) IRegex(dbField, pattern string) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s ~* %s", b.ident(dbField), argMarker), pattern)
+}
+
+// 添加正则不匹配条件(!~)，区分大小写
+func (b *ConditionBuilder) NotRegex(dbField, pattern string) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s !~ %s", b.ident(dbField), argMarker), pattern)
+}
+
+// 添加正则不匹配条件(!~*)，不区分大小写
+func (b *ConditionBuilder) NotIRegex(dbField, pattern string) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s !~* %s", b.ident(dbField), argMarker), pattern)
+}
+
 // 添加BETWEEN条件
 func (b *ConditionBuilder) Between(dbField string, start, end interface{}) *ConditionBuilder {
-	return b.Where(fmt.Sprintf("%s BETWEEN %s AND %s",
-		dbField, ToString(start), ToString(end)))
+	return b.where(fmt.Sprintf("%s BETWEEN %s AND %s",
+		b.ident(dbField), argMarker, argMarker), start, end)
+}
+
+// 结构化的过滤条件，Op可选 eq、ne、gt、gte、lt、lte、like、in、nin
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// 按Filter.Op逐个添加条件，Op不支持时panic
+func (b *ConditionBuilder) WhereFilters(filters []Filter) *ConditionBuilder {
+	for _, f := range filters {
+		switch f.Op {
+		case "eq":
+			b.Equal(f.Field, f.Value)
+		case "ne":
+			b.NotEqual(f.Field, f.Value)
+		case "gt":
+			b.Gt(f.Field, f.Value)
+		case "gte":
+			b.Gte(f.Field, f.Value)
+		case "lt":
+			b.Lt(f.Field, f.Value)
+		case "lte":
+			b.Lte(f.Field, f.Value)
+		case "like":
+			value, ok := f.Value.(string)
+			if !ok {
+				log.Panicf("sqlol: like filter value of %s must be string, got %T", f.Field, f.Value)
+			}
+			b.Like(f.Field, value)
+		case "in", "nin":
+			// tip: 过滤条件的值只能是array/slice，不使用MustIn(会接受*Builder子查询)
+			if kind := reflect.ValueOf(f.Value).Kind(); kind != reflect.Array && kind != reflect.Slice {
+				log.Panicf("sqlol: %s filter value of %s must be array or slice, got %T", f.Op, f.Field, f.Value)
+			}
+			if f.Op == "in" {
+				b.In(f.Field, f.Value)
+			} else {
+				b.NotIn(f.Field, f.Value)
+			}
+		default:
+			log.Panicf("sqlol: unknown filter op %q of %s", f.Op, f.Field)
+		}
+	}
+	return b
 }
 
 // 添加IN条件
 func (b *ConditionBuilder) In(dbField string, values interface{}) *ConditionBuilder {
-	if condition := buildInCondition(dbField, values); condition != "" {
-		return b.Where(condition)
+	if condition, args := buildInCondition(b.ident(dbField), values); condition != "" {
+		return b.where(condition, args...)
+	}
+	return b.WhereFalse()
+}
+
+// 添加 COALESCE(dbField, def) IN (...) 条件，dbField为NULL时按def匹配，
+// 如 InCoalesce("status", "pending", []string{"pending","done"}) 会包含status为NULL的行，
+// values为空时与In相同，条件恒为false
+func (b *ConditionBuilder) InCoalesce(dbField string, def interface{}, values interface{}) *ConditionBuilder {
+	args := sliceArgs(values)
+	if len(args) == 0 {
+		return b.WhereFalse()
+	}
+	return b.where(fmt.Sprintf("COALESCE(%s, %s) IN (%s)", b.ident(dbField), argMarker, argList(len(args))),
+		append([]interface{}{def}, args...)...)
+}
+
+// 添加IN条件，与In不同，values不是array/slice/*Builder时直接panic，避免误传标量被当成1=0
+// values 可传类型：
+//
+//	*Builder: 子查询，效果同InSubBuilder
+//	array/slice: 结果集，效果同In
+//
+// tip: 不接受字符串，避免用户输入被当作子查询拼接，子查询sql请使用InSubQuery
+func (b *ConditionBuilder) MustIn(dbField string, values interface{}) *ConditionBuilder {
+	if sub, ok := values.(*Builder); ok && sub != nil {
+		return b.InSubBuilder(dbField, sub)
+	}
+	switch reflect.ValueOf(values).Kind() {
+	case reflect.Array, reflect.Slice:
+		return b.In(dbField, values)
+	default:
+		log.Panicf("sqlol: MustIn values of %s must be array, slice or *Builder, got %T", dbField, values)
+		return b
 	}
-	return b.Where("1=0")
 }
 
 // 添加IN条件，value为零值时跳过
 func (b *ConditionBuilder) TryIn(dbField string, values interface{}) *ConditionBuilder {
-	if condition := buildInCondition(dbField, values); condition != "" {
-		return b.Where(condition)
+	if condition, args := buildInCondition(b.ident(dbField), values); condition != "" {
+		return b.where(condition, args...)
 	}
 	return b
 }
 
+// 添加IN条件，先剔除values中的零值元素(如空字符串)，剔除后为空时跳过
+func (b *ConditionBuilder) TryInNonEmpty(dbField string, values interface{}) *ConditionBuilder {
+	return b.TryIn(dbField, nonEmptyElements(values))
+}
+
 // 添加NOT IN条件
 func (b *ConditionBuilder) NotIn(dbField string, values interface{}) *ConditionBuilder {
-	if condition := buildNotInCondition(dbField, values); condition != "" {
-		return b.Where(condition)
+	if condition, args := buildNotInCondition(b.ident(dbField), values); condition != "" {
+		return b.where(condition, args...)
+	}
+	return b
+}
+
+// 添加NULL安全的NOT IN条件：values不含NULL时dbField为NULL的行也会被返回，
+// values含NULL时仅返回dbField非NULL且不在values中的行
+func (b *ConditionBuilder) NotInStrict(dbField string, values interface{}) *ConditionBuilder {
+	if condition, args := buildNotInStrictCondition(b.ident(dbField), values); condition != "" {
+		return b.where(condition, args...)
 	}
 	return b
 }
@@ -142,20 +711,83 @@ func (b *ConditionBuilder) NotIn(dbField string, values interface{}) *ConditionB
 // 		string: 子查询sql
 // 		array/slice: 结果集，效果同In
 func (b *ConditionBuilder) Any(dbField string, values interface{}) *ConditionBuilder {
-	if condition := buildAnyCondition(dbField, values); condition != "" {
-		return b.Where(condition)
+	if condition, args := buildAnyCondition(b.ident(dbField), values); condition != "" {
+		return b.where(condition, args...)
 	}
-	return b.Where("1=0")
+	return b.WhereFalse()
 }
 
 // 添加IN条件，value为零值时跳过
 func (b *ConditionBuilder) TryAny(dbField string, values interface{}) *ConditionBuilder {
-	if condition := buildAnyCondition(dbField, values); condition != "" {
-		return b.Where(condition)
+	if condition, args := buildAnyCondition(b.ident(dbField), values); condition != "" {
+		return b.where(condition, args...)
 	}
 	return b
 }
 
+// 添加范围包含条件(@>)，如 during @> '2020-05-01T00:00:00Z'::timestamptz，
+// value为time.Time时转为timestamptz，也可以传范围字面值判断范围包含
+func (b *ConditionBuilder) RangeContains(dbField string, value interface{}) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s @> %s", b.ident(dbField), rangeElement(value)), value)
+}
+
+// 添加范围重叠条件(&&)，other一般为范围字面值，如 '[2020-05-01,2020-06-01)'
+func (b *ConditionBuilder) RangeOverlaps(dbField string, other interface{}) *ConditionBuilder {
+	return b.where(fmt.Sprintf("%s && %s", b.ident(dbField), argMarker), other)
+}
+
+func rangeElement(value interface{}) string {
+	switch value.(type) {
+	case time.Time, *time.Time:
+		return argMarker + "::timestamptz"
+	}
+	return argMarker
+}
+
+// 添加EXISTS子查询条件
+func (b *ConditionBuilder) Exists(sub *Builder) *ConditionBuilder {
+	sql, args := sub.render((*Builder).build)
+	return b.where("EXISTS ("+strings.TrimSpace(sql)+")", args...)
+}
+
+// 添加NOT EXISTS子查询条件
+func (b *ConditionBuilder) NotExists(sub *Builder) *ConditionBuilder {
+	sql, args := sub.render((*Builder).build)
+	return b.where("NOT EXISTS ("+strings.TrimSpace(sql)+")", args...)
+}
+
+// 添加IN子查询条件，如 id IN (SELECT user_id FROM ...)，subQuery原样使用
+func (b *ConditionBuilder) InSubQuery(dbField, subQuery string) *ConditionBuilder {
+	return b.Where(fmt.Sprintf("%s IN (%s)", b.ident(dbField), subQuery))
+}
+
+// 添加NOT IN子查询条件
+func (b *ConditionBuilder) NotInSubQuery(dbField, subQuery string) *ConditionBuilder {
+	return b.Where(fmt.Sprintf("%s NOT IN (%s)", b.ident(dbField), subQuery))
+}
+
+// 与InSubQuery相同，子查询由sub生成，参数会合并到当前条件中
+func (b *ConditionBuilder) InSubBuilder(dbField string, sub *Builder) *ConditionBuilder {
+	sql, args := sub.render((*Builder).build)
+	return b.where(fmt.Sprintf("%s IN (%s)", b.ident(dbField), strings.TrimSpace(sql)), args...)
+}
+
+// 与NotInSubQuery相同，子查询由sub生成
+func (b *ConditionBuilder) NotInSubBuilder(dbField string, sub *Builder) *ConditionBuilder {
+	sql, args := sub.render((*Builder).build)
+	return b.where(fmt.Sprintf("%s NOT IN (%s)", b.ident(dbField), strings.TrimSpace(sql)), args...)
+}
+
+// 添加多列IN子查询条件，如 (a,b) IN (SELECT a, b FROM ...)
+func (b *ConditionBuilder) InTupleSubQuery(dbFields []string, sub *Builder) *ConditionBuilder {
+	fields := make([]string, len(dbFields))
+	for i, field := range dbFields {
+		fields[i] = b.ident(field)
+	}
+	sql, args := sub.render((*Builder).build)
+	return b.where(fmt.Sprintf("(%s) IN (%s)", strings.Join(fields, ","), strings.TrimSpace(sql)), args...)
+}
+
 // 添加时间范围条件，value为零值时跳过
 func (b *ConditionBuilder) TryTimeRange(
 	dbField string, startTime, endTime time.Time) *ConditionBuilder {
@@ -163,10 +795,10 @@ func (b *ConditionBuilder) TryTimeRange(
 		return b.Between(dbField, startTime, endTime)
 	}
 	if !startTime.IsZero() {
-		return b.Where(fmt.Sprintf("%s >= %s", dbField, ToString(startTime)))
+		return b.where(fmt.Sprintf("%s >= %s", b.ident(dbField), argMarker), startTime)
 	}
 	if !endTime.IsZero() {
-		return b.Where(fmt.Sprintf("%s <= %s", dbField, ToString(endTime)))
+		return b.where(fmt.Sprintf("%s <= %s", b.ident(dbField), argMarker), endTime)
 	}
 	return b
 }
@@ -183,30 +815,51 @@ func (b *ConditionBuilder) TryDateRange(
 	return b.TryTimeRange(dbField, startDate, endDate)
 }
 
-func buildInCondition(field string, values interface{}) string {
-	if v := sliceValue(values); v != "" {
-		return fmt.Sprintf("%s IN (%s)", field, v)
+// tip: col IN (NULL) 永远不会匹配，NULL元素单独转为 col IS NULL
+func buildInCondition(field string, values interface{}) (string, []interface{}) {
+	args, hasNull := nullableSliceArgs(values)
+	switch {
+	case len(args) > 0 && hasNull:
+		return fmt.Sprintf("%s IN (%s) OR %s IS NULL", field, argList(len(args)), field), args
+	case len(args) > 0:
+		return fmt.Sprintf("%s IN (%s)", field, argList(len(args))), args
+	case hasNull:
+		return fmt.Sprintf("%s IS NULL", field), nil
 	}
-	return ""
+	return "", nil
 }
-func buildNotInCondition(field string, values interface{}) string {
-	if v := sliceValue(values); v != "" {
-		return fmt.Sprintf("%s NOT IN (%s)", field, v)
+func buildNotInCondition(field string, values interface{}) (string, []interface{}) {
+	if args := sliceArgs(values); len(args) > 0 {
+		return fmt.Sprintf("%s NOT IN (%s)", field, argList(len(args))), args
 	}
-	return ""
+	return "", nil
 }
 
-func buildAnyCondition(field string, values interface{}) string {
+// tip: col NOT IN (...) 在col为NULL或values含NULL时永远不会匹配
+func buildNotInStrictCondition(field string, values interface{}) (string, []interface{}) {
+	args, hasNull := nullableSliceArgs(values)
+	switch {
+	case len(args) > 0 && hasNull:
+		return fmt.Sprintf("%s IS NOT NULL AND %s NOT IN (%s)", field, field, argList(len(args))), args
+	case len(args) > 0:
+		return fmt.Sprintf("%s IS NULL OR %s NOT IN (%s)", field, field, argList(len(args))), args
+	case hasNull:
+		return fmt.Sprintf("%s IS NOT NULL", field), nil
+	}
+	return "", nil
+}
+
+func buildAnyCondition(field string, values interface{}) (string, []interface{}) {
 	switch values.(type) {
 	case string:
 		if values == "" {
-			return ""
+			return "", nil
 		}
-		return fmt.Sprintf("%s = ANY(%s)", field, values)
+		return fmt.Sprintf("%s = ANY(%s)", field, values), nil
 	default:
-		if v := sliceValue(values); v != "" {
-			return fmt.Sprintf("%s = ANY(ARRAY[%s])", field, v)
+		if args := sliceArgs(values); len(args) > 0 {
+			return fmt.Sprintf("%s = ANY(ARRAY[%s])", field, argList(len(args))), args
 		}
-		return ""
+		return "", nil
 	}
 }
@@ -2,15 +2,17 @@ package sqlol
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestConditionBuilder(t *testing.T) {
-	ExampleCondition()
-	ExampleCondition2()
+	ExampleConditionBuilder()
+	ExampleConditionBuilder_second()
 }
 
-func ExampleCondition() {
+func ExampleConditionBuilder() {
 	builder := ConditionBuilder{}
 
 	// Where
@@ -54,7 +56,7 @@ func ExampleCondition() {
 	builder.Clear()
 }
 
-func ExampleCondition2() {
+func ExampleConditionBuilder_second() {
 	builder := ConditionBuilder{}
 
 	// Between
@@ -74,3 +76,422 @@ func ExampleCondition2() {
 	*/
 	builder.Clear()
 }
+
+func TestConditionBuilder_Combinator(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.Equal("a", 1).Equal("b", 2)
+	if got, want := builder.Build(), "(a = 1) AND (b = 2)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Combinator("or")
+	if got, want := builder.Build(), "(a = 1) OR (b = 2)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Clear()
+	builder.Equal("a", 1).Equal("b", 2)
+	if got, want := builder.Build(), "(a = 1) AND (b = 2)"; got != want {
+		t.Errorf("Build() after Clear() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_InWithNull(t *testing.T) {
+	var nilPtr *int
+	tests := []struct {
+		name   string
+		values interface{}
+		want   string
+	}{
+		{"no null", []interface{}{1, "a"}, "(a IN (1,'a'))"},
+		{"with null", []interface{}{1, "a", nil}, "(a IN (1,'a') OR a IS NULL)"},
+		{"nil pointer", []interface{}{2, nilPtr}, "(a IN (2) OR a IS NULL)"},
+		{"only null", []interface{}{nil}, "(a IS NULL)"},
+		{"empty", []interface{}{}, "(1=0)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := ConditionBuilder{}
+			if got := builder.In("a", tt.values).Build(); got != tt.want {
+				t.Errorf("In() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionBuilder_Group(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.Equal("a", 1).
+		BeginGroup().
+		Equal("b", 2).
+		BeginGroup().Or("c = 3", "d = 4").EndGroup().
+		EndGroup().
+		Equal("e", 5)
+	want := "(a = 1) AND ((b = 2) AND (((c = 3) OR (d = 4)))) AND (e = 5)"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	builder.Clear()
+	builder.BeginGroup().EndGroup().Equal("a", 1)
+	if got, want := builder.Build(), "(a = 1)"; got != want {
+		t.Errorf("Build() with empty group = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_IsTrue(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.IsTrue("a").IsFalse("b").Equal("c", true)
+	if got, want := builder.Build(), "(a IS TRUE) AND (b IS FALSE) AND (c = true)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_Regex(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(b *ConditionBuilder) *ConditionBuilder
+		want string
+	}{
+		{"regex", func(b *ConditionBuilder) *ConditionBuilder {
+			return b.Regex("a", `^\d+$`)
+		}, `(a ~ '^\d+$')`},
+		{"iregex", func(b *ConditionBuilder) *ConditionBuilder {
+			return b.IRegex("a", "^abc")
+		}, "(a ~* '^abc')"},
+		{"not regex", func(b *ConditionBuilder) *ConditionBuilder {
+			return b.NotRegex("a", "it's")
+		}, "(a !~ 'it''s')"},
+		{"not iregex", func(b *ConditionBuilder) *ConditionBuilder {
+			return b.NotIRegex("a", "x")
+		}, "(a !~* 'x')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fn(&ConditionBuilder{}).Build(); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionBuilder_MustIn(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.MustIn("a", []int{1, 2}).
+		MustIn("b", NewBuilder().Select("a.user").Fields("id").Equal("status", 1))
+	if got, want := trimSQL(builder.Build()), "(a IN (1,2)) AND (b IN (SELECT id FROM a.user WHERE (status = 1)))"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	for _, values := range []interface{}{5, "1) OR (1=1", (*Builder)(nil)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MustIn(%#v) should panic", values)
+				}
+			}()
+			builder.MustIn("a", values)
+		}()
+	}
+}
+
+func TestConditionBuilder_TryInNonEmpty(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.TryInNonEmpty("a", []string{"a", "", "c"}).TryInNonEmpty("b", []string{"", ""})
+	if got, want := builder.Build(), "(a IN ('a','c'))"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	b := NewBuilder().Select("a.user").
+		Strategies(TryIn{Field: "name", Values: []string{"", "b"}, OmitEmpty: true})
+	if got, want := b.ConditionBuilder.Build(), "(name IN ('b'))"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_Range(t *testing.T) {
+	now, _ := time.Parse(TimeLayout, "2020-05-01 00:00:00")
+	builder := ConditionBuilder{}
+	builder.RangeContains("during", now).
+		RangeContains("during", "[2020-05-01,2020-05-02)").
+		RangeOverlaps("during", "[2020-05-01,2020-06-01)")
+	want := "(during @> '2020-05-01T00:00:00Z'::timestamptz) AND " +
+		"(during @> '[2020-05-01,2020-05-02)') AND " +
+		"(during && '[2020-05-01,2020-06-01)')"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_WhereFalse(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.Equal("a", 1).WhereFalse()
+	if got, want := builder.Build(), "(a = 1) AND (1=0)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Clear()
+	builder.WhereTrue().Combinator("OR").Equal("a", 1)
+	if got, want := builder.Build(), "(1=1) OR (a = 1)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_EqualEnum(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.EqualEnum("status", "order_status", "paid").EqualEnum("kind", "order_kind", nil)
+	if got, want := builder.Build(), "(status = 'paid'::order_status) AND (kind IS NULL)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_NotInStrict(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.NotInStrict("a", []int{1, 2})
+	if got, want := builder.Build(), "(a IS NULL OR a NOT IN (1,2))"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Clear()
+	builder.NotInStrict("a", []interface{}{1, nil})
+	if got, want := builder.Build(), "(a IS NOT NULL AND a NOT IN (1))"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Clear()
+	builder.NotInStrict("a", []interface{}{nil}).NotInStrict("b", []int{})
+	if got, want := builder.Build(), "(a IS NOT NULL)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_EqualAllAny(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.EqualAll([]string{"a", "b"}, 1).EqualAny([]string{"c", "d"}, "x")
+	if got, want := builder.Build(), "(a = 1) AND (b = 1) AND (c = 'x' OR d = 'x')"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Clear()
+	builder.EqualAll([]string{"a"}, nil).EqualAny([]string{"c", "d"}, nil).EqualAny(nil, 1)
+	if got, want := builder.Build(), "(a IS NULL) AND (c IS NULL OR d IS NULL)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_WhereFilters(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.WhereFilters([]Filter{
+		{"a", "eq", 1},
+		{"b", "ne", "x"},
+		{"c", "gt", 2},
+		{"d", "gte", 3},
+		{"e", "lt", 4},
+		{"f", "lte", 5},
+		{"g", "like", "y"},
+		{"h", "in", []int{6, 7}},
+		{"i", "nin", []string{"z"}},
+	})
+	want := "(a = 1) AND (b <> 'x') AND (c > 2) AND (d >= 3) AND (e < 4) AND (f <= 5) AND " +
+		"(g LIKE '%y%') AND (h IN (6,7)) AND (i NOT IN ('z'))"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	for _, f := range []Filter{{"a", "regex", "x"}, {"a", "in", "select 1"}, {"a", "like", 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WhereFilters(%v) should panic", f)
+				}
+			}()
+			new(ConditionBuilder).WhereFilters([]Filter{f})
+		}()
+	}
+}
+
+func TestConditionBuilder_Compare(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.Gt("a", 1).Gte("b", 2).Lt("c", 3).Lte("d", "x").NotEqual("e", 5).NotEqual("f", nil)
+	want := "(a > 1) AND (b >= 2) AND (c < 3) AND (d <= 'x') AND (e <> 5) AND (f IS NOT NULL)"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	builder.Clear()
+	builder.TryGt("a", 0).TryGte("b", "").TryLt("c", 3).TryLte("d", time.Time{}).TryNotEqual("e", 0)
+	if got, want := builder.Build(), "(c < 3)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_IsNull(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.IsNull("a").IsNotNull("b")
+	if got, want := builder.Build(), "(a IS NULL) AND (b IS NOT NULL)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_WhereGroup(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.WhereGroup(func(cb *ConditionBuilder) {
+		cb.Equal("a", 1).Equal("b", 2)
+	}).OrGroup(func(cb *ConditionBuilder) {
+		cb.Equal("c", 3)
+	}).WhereGroup(func(cb *ConditionBuilder) {
+		cb.In("d", []int{4, 5}).WhereGroup(func(cb *ConditionBuilder) {
+			cb.Combinator("OR").Like("e", "x").Equal("f", nil)
+		})
+	}).WhereGroup(func(cb *ConditionBuilder) {})
+	want := "(((a = 1) AND (b = 2)) OR (c = 3)) AND " +
+		"((d IN (4,5)) AND ((e LIKE '%x%') OR (f IS NULL)))"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	builder.Clear()
+	builder.OrGroup(func(cb *ConditionBuilder) { cb.Equal("a", 1) })
+	if got, want := builder.Build(), "(a = 1)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_TryEqualTime(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	var nilTime *time.Time
+	builder := ConditionBuilder{}
+	builder.TryEqual("a", time.Time{}).
+		TryEqual("b", time.Time{}.In(loc)).
+		TryEqual("c", nilTime).
+		TryEqual("d", nil).
+		TryEqual("e", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	if got, want := builder.Build(), "(e = '2020-05-01T00:00:00Z')"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_ILike(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.ILike("a", "x").TryILike("b", " ").NotLike("c", "it's").TryNotLike("d", "").
+		MultiILike([]string{"e", "f"}, "y").TryMultiILike([]string{"g"}, "")
+	want := "(a ILIKE '%x%') AND (c NOT LIKE '%it''s%') AND ((e ILIKE '%y%') OR (f ILIKE '%y%'))"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_CompareInterval(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.Gt("duration", Interval("1 hour")).Lte("duration", Interval("1 day")).
+		TryGte("timeout", Interval(""))
+	want := "(duration > INTERVAL '1 hour') AND (duration <= INTERVAL '1 day')"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_BuildGrouped(t *testing.T) {
+	builder := ConditionBuilder{}
+	if got := builder.BuildGrouped(); got != "" {
+		t.Errorf("BuildGrouped() = %v, want empty", got)
+	}
+	builder.Equal("a", 1)
+	if got, want := builder.BuildGrouped(), "(a = 1)"; got != want {
+		t.Errorf("BuildGrouped() = %v, want %v", got, want)
+	}
+	builder.Combinator("OR").Equal("b", 2)
+	if got, want := builder.BuildGrouped(), "((a = 1) OR (b = 2))"; got != want {
+		t.Errorf("BuildGrouped() = %v, want %v", got, want)
+	}
+	if got, want := "x IS NULL AND "+builder.BuildGrouped(), "x IS NULL AND ((a = 1) OR (b = 2))"; got != want {
+		t.Errorf("BuildGrouped() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_CompareColumns(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.CompareColumns("updated_at", ">", "created_at").
+		CompareColumns("o.user_id", "is distinct from", "u.id")
+	want := "(updated_at > created_at) AND (o.user_id IS DISTINCT FROM u.id)"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if _, args := builder.build(); len(args) != 0 {
+		t.Errorf("build() args = %v, want none", args)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("CompareColumns() expected panic on unsupported operator")
+		}
+	}()
+	builder.CompareColumns("a", "; DROP", "b")
+}
+
+func TestConditionBuilder_DedupeConditions(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.DedupeConditions().
+		Equal("status", "active").
+		Gt("age", 18).
+		Equal("status", "active").
+		Equal("status", "deleted").
+		Where("a = b").
+		Where("a = b")
+	want := "(status = 'active') AND (age > 18) AND (status = 'deleted') AND (a = b)"
+	if got := builder.Build(); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if _, args := builder.build(); !reflect.DeepEqual(args, []interface{}{"active", 18, "deleted"}) {
+		t.Errorf("build() args = %v", args)
+	}
+
+	builder.Clear()
+	builder.DedupeConditions().Equal("a", 1).Equal("a", 1)
+	if got, want := builder.BuildGrouped(), "(a = 1)"; got != want {
+		t.Errorf("BuildGrouped() = %v, want %v", got, want)
+	}
+
+	builder.Clear()
+	builder.Equal("a", 1).Equal("a", 1)
+	if got, want := builder.Build(), "(a = 1) AND (a = 1)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_InCoalesce(t *testing.T) {
+	builder := ConditionBuilder{}
+	builder.InCoalesce("status", "pending", []string{"pending", "done"})
+	if got, want := builder.Build(), "(COALESCE(status, 'pending') IN ('pending','done'))"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	if _, args := builder.build(); !reflect.DeepEqual(args, []interface{}{"pending", "pending", "done"}) {
+		t.Errorf("build() args = %v", args)
+	}
+	builder.Clear()
+	builder.InCoalesce("status", "pending", []string{})
+	if got, want := builder.Build(), "(1=0)"; got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestConditionBuilder_MultiSearch(t *testing.T) {
+	tests := []struct {
+		mode SearchMode
+		want string
+	}{
+		{"", "((name ILIKE '%Ab%') OR (remark ILIKE '%Ab%'))"},
+		{SearchILike, "((name ILIKE '%Ab%') OR (remark ILIKE '%Ab%'))"},
+		{SearchLower, "((LOWER(name) LIKE LOWER('%Ab%')) OR (LOWER(remark) LIKE LOWER('%Ab%')))"},
+		{SearchTrigram, "((name % 'Ab') OR (remark % 'Ab'))"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			builder := ConditionBuilder{}
+			if tt.mode != "" {
+				builder.SearchMode(tt.mode)
+			}
+			builder.MultiSearch([]string{"name", "remark"}, "Ab").TryMultiSearch([]string{"name"}, " ")
+			if got := builder.Build(); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("SearchMode() expected panic on unsupported mode")
+		}
+	}()
+	(&ConditionBuilder{}).SearchMode("REGEX")
+}
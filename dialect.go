@@ -0,0 +1,167 @@
+package sqlol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 数据库方言，决定参数占位符、字符串及标识符转义、LIMIT/OFFSET、冲突更新及RETURNING的写法，
+// 未设置时按Postgres生成
+type Dialect interface {
+	// 第n个(从1开始)参数的占位符，如 $1、?
+	Placeholder(n int) string
+	// 生成字符串字面值，Build内联参数时使用，如 'it''s'
+	QuoteString(s string) string
+	// 转义标识符，按.拆分后分别转义，如 o.status => "o"."status"
+	QuoteIdentifier(ident string) string
+	// LIMIT/OFFSET子句，offset为0时省略
+	LimitOffset(limit, offset int64) string
+	// 冲突更新时引用待插入的col值，如 EXCLUDED.col
+	Excluded(col string) string
+	// 冲突时执行assignments(如 a = EXCLUDED.a)的子句，conflictFields为冲突目标，不需要时可忽略
+	Upsert(conflictFields, assignments []string) string
+	// 返回fields的子句，如 RETURNING id，不支持时返回错误
+	Returning(fields []string) (string, error)
+}
+
+var (
+	Postgres Dialect = postgres{}
+	MySQL    Dialect = mysql{}
+)
+
+// 使用命名参数的方言，如 :p1，Prepare时据此填充Names
+type NamedDialect interface {
+	Dialect
+	// 第n个(从1开始)参数的名称，与Placeholder(n)对应，如 p1
+	ParamName(n int) string
+}
+
+// 支持按百分比限制行数的方言，内置的Postgres、MySQL均不支持
+type PercentDialect interface {
+	Dialect
+	// 按percent百分比限制行数的子句，如 FETCH FIRST 10 PERCENT ROWS ONLY，offset为0时省略
+	LimitPercent(percent float64, offset int64) string
+}
+
+// 以参数标记生成LIMIT/OFFSET子句的方言，BuildArgs时limit、offset作为参数绑定，
+// 未实现时按LimitOffset内联
+type limitOffsetMarker interface {
+	// limit、offset为参数标记，原样拼接即可，offset为空时省略
+	limitOffsetMarker(limit, offset string) string
+}
+
+// 将limit、offset转为LimitOffset需要的字符串，offset为0时为空
+func limitOffsetString(limit, offset int64) (string, string) {
+	if offset > 0 {
+		return strconv.FormatInt(limit, 10), strconv.FormatInt(offset, 10)
+	}
+	return strconv.FormatInt(limit, 10), ""
+}
+
+var (
+	errConflictDialect  = errors.New("sqlol: OnConflict is only supported by Postgres, use OnConflictDoUpdate instead")
+	errReturningDialect = errors.New("sqlol: RETURNING is not supported by MySQL")
+	errPercentDialect   = errors.New("sqlol: LimitPercent is not supported by the dialect, use a PercentDialect")
+)
+
+type postgres struct{}
+
+func (postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgres) QuoteString(s string) string {
+	return String(s)
+}
+
+func (postgres) QuoteIdentifier(ident string) string {
+	return Quote(ident)
+}
+
+func (p postgres) LimitOffset(limit, offset int64) string {
+	return p.limitOffsetMarker(limitOffsetString(limit, offset))
+}
+
+func (postgres) limitOffsetMarker(limit, offset string) string {
+	sql := "LIMIT " + limit
+	if offset != "" {
+		sql += " OFFSET " + offset
+	}
+	return sql
+}
+
+func (postgres) Excluded(col string) string {
+	return "EXCLUDED." + col
+}
+
+func (postgres) Upsert(conflictFields, assignments []string) string {
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s",
+		strings.Join(conflictFields, ","), strings.Join(assignments, ","))
+}
+
+func (postgres) Returning(fields []string) (string, error) {
+	return "RETURNING " + strings.Join(fields, ","), nil
+}
+
+type mysql struct{}
+
+func (mysql) Placeholder(n int) string {
+	return "?"
+}
+
+// tip: MySQL默认将反斜杠视为转义字符，需一并转义，否则 x\ 会转义掉结尾的引号
+var mysqlStringReplacer = strings.NewReplacer(`\`, `\\`, `'`, `''`, "\x00", "")
+
+func (mysql) QuoteString(s string) string {
+	return "'" + mysqlStringReplacer.Replace(s) + "'"
+}
+
+func (mysql) QuoteIdentifier(ident string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = "`" + strings.Replace(part, "`", "``", -1) + "`"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (m mysql) LimitOffset(limit, offset int64) string {
+	return m.limitOffsetMarker(limitOffsetString(limit, offset))
+}
+
+func (mysql) limitOffsetMarker(limit, offset string) string {
+	if offset != "" {
+		return "LIMIT " + offset + ", " + limit
+	}
+	return "LIMIT " + limit
+}
+
+func (mysql) Excluded(col string) string {
+	return "VALUES(" + col + ")"
+}
+
+// tip: MySQL根据主键或唯一索引判断冲突，conflictFields被忽略
+func (mysql) Upsert(conflictFields, assignments []string) string {
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ",")
+}
+
+func (mysql) Returning(fields []string) (string, error) {
+	return "", errReturningDialect
+}
+
+// 设置数据库方言，默认为Postgres
+func (b *Builder) Dialect(d Dialect) *Builder {
+	b.ConditionBuilder.dialect = d
+	b.HavingBuilder.dialect = d
+	return b
+}
+
+func (b *Builder) dialect() Dialect {
+	if d := b.ConditionBuilder.dialect; d != nil {
+		return d
+	}
+	return Postgres
+}
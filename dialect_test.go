@@ -0,0 +1,105 @@
+package sqlol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBuilder_DialectMySQL(t *testing.T) {
+	builder := NewBuilder().Dialect(MySQL).Select("user").
+		QuoteIdentifiers().
+		Equal("u.name", "a").
+		In("id", []int{1, 2}).
+		OrderBy("id DESC").
+		Limit(10).Offset(20)
+	want := "SELECT * FROM `user` WHERE (`u`.`name` = 'a') AND (`id` IN (1,2)) ORDER BY `id` DESC LIMIT 20, 10"
+	if got := trimSQL(builder.Clone().Build()); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql, args := builder.BuildArgs()
+	want = "SELECT * FROM `user` WHERE (`u`.`name` = ?) AND (`id` IN (?,?)) ORDER BY `id` DESC LIMIT ?, ?"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a", 1, 2, int64(20), int64(10)}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+
+	sql = NewBuilder().Dialect(MySQL).Insert("user").Cols("Name", "Remark").
+		Values(User{Name: "a", Remark: "b"}).
+		OnConflictDoUpdate([]string{"name"}).
+		Build()
+	want = "INSERT INTO user(name,remark) VALUES ('a','b') ON DUPLICATE KEY UPDATE remark = VALUES(remark)"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+
+	_, err := NewBuilder().Dialect(MySQL).Insert("user").Cols("Name").
+		Values(User{Name: "a"}).OnConflictDoNothing().BuildE()
+	if !errors.Is(err, errConflictDialect) {
+		t.Errorf("BuildE() error = %v, want %v", err, errConflictDialect)
+	}
+
+	_, err = NewBuilder().Dialect(MySQL).Update("user").Set("name = 'a'").
+		Equal("id", 1).Returning("id").BuildE()
+	if !errors.Is(err, errReturningDialect) {
+		t.Errorf("BuildE() error = %v, want %v", err, errReturningDialect)
+	}
+}
+
+func TestBuilder_DialectMySQLString(t *testing.T) {
+	sql := NewBuilder().Dialect(MySQL).Select("user").
+		Equal("name", `x\`).
+		OrderByCoalesce("remark", `it's\`, false).
+		Build()
+	want := `SELECT * FROM user WHERE (name = 'x\\') ORDER BY COALESCE(remark, 'it''s\\')`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+	sql = NewBuilder().Select("user").Equal("name", `x\`).Build()
+	want = `SELECT * FROM user WHERE (name = 'x\')`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_DialectPostgres(t *testing.T) {
+	sql, args := NewBuilder().Dialect(Postgres).Select("a.user").
+		QuoteIdentifiers().
+		Equal("name", "a").
+		Limit(10).Offset(20).
+		BuildArgs()
+	want := `SELECT * FROM "a"."user" WHERE ("name" = $1) LIMIT $2 OFFSET $3`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a", int64(10), int64(20)}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+}
+
+// 第三方方言，只实现Dialect的方法
+type testDialect struct {
+	Dialect
+}
+
+func TestBuilder_DialectCustom(t *testing.T) {
+	sql, args := NewBuilder().Dialect(testDialect{Postgres}).Select("user").
+		Equal("name", "a").
+		Limit(10).Offset(20).
+		BuildArgs()
+	want := `SELECT * FROM user WHERE (name = $1) LIMIT 10 OFFSET 20`
+	if got := trimSQL(sql); got != want {
+		t.Errorf("BuildArgs() sql = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"a"}) {
+		t.Errorf("BuildArgs() args = %v", args)
+	}
+	if got := MySQL.LimitOffset(10, 20); got != "LIMIT 20, 10" {
+		t.Errorf("LimitOffset() = %v", got)
+	}
+	if got := Postgres.LimitOffset(10, 0); got != "LIMIT 10" {
+		t.Errorf("LimitOffset() = %v", got)
+	}
+}
@@ -0,0 +1,93 @@
+package sqlol
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+var (
+	ErrNoManipulation    = errors.New("sqlol: manipulation is required")
+	ErrNoTable           = errors.New("sqlol: table is required")
+	ErrNoValues          = errors.New("sqlol: values are required")
+	ErrNoCols            = errors.New("sqlol: inserting fields are required")
+	ErrNoDeleteCondition = errors.New("sqlol: deleting condition is required")
+	ErrReadOnly          = errors.New("sqlol: data-modifying statement is not allowed in read-only mode")
+	ErrNotSelect         = errors.New("sqlol: must be a select operation")
+	ErrEstimateSubQuery  = errors.New("sqlol: count estimate is not available for sub query")
+	ErrEstimateFiltered  = errors.New("sqlol: count estimate only applies to unfiltered counts")
+	ErrNoLookup          = errors.New("sqlol: lookup condition is required")
+	ErrNoKeyField        = errors.New("sqlol: no primary key field in struct")
+)
+
+// 生成sql失败的错误，记录出错的操作类型，可通过errors.Is判断具体原因，如 errors.Is(err, ErrNoTable)
+type BuildError struct {
+	Manipulation string
+	Err          error
+}
+
+func (e *BuildError) Error() string {
+	if e.Manipulation == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + " in " + e.Manipulation
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// 为false时Build、BuildCount、BuildArgs不再panic，而是记录第一个错误并返回空sql，
+// 错误通过Builder.Err获取，用于逐步从panic迁移到返回错误
+var PanicOnError = true
+
+// 返回PanicOnError为false时生成sql过程中记录的第一个错误
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// 以BuildError中断sql的生成，由BuildE等方法转换为返回的错误
+func (b *Builder) fail(err error) {
+	panic(&BuildError{Manipulation: b.manipulation, Err: err})
+}
+
+// 处理Build等方法中的错误：PanicOnError时panic，否则记录第一个错误
+func (b *Builder) handleErr(err error) {
+	if PanicOnError {
+		log.Panic(err)
+	}
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// 将生成sql过程中的BuildError及本包log.Panic的panic转换为错误，必须直接defer调用，
+// 其它panic(如运行时错误)原样抛出
+func recoverBuildError(err *error) {
+	r := recover()
+	switch v := r.(type) {
+	case nil:
+	case *BuildError:
+		*err = v
+	case string:
+		if !strings.HasPrefix(v, "sqlol") {
+			panic(r)
+		}
+		*err = errors.New(v)
+	default:
+		panic(r)
+	}
+}
+
+// 检查builder的所有问题并全部返回，如缺少表名、缺少插入值、子句与操作不匹配等，
+// 以及Err记录的错误，没有问题时返回nil；结构问题与Validate一样包装为*BuildError
+func (b *Builder) Errors() []error {
+	var errs []error
+	if b.err != nil {
+		errs = append(errs, b.err)
+	}
+	for _, err := range b.structuralErrors() {
+		errs = append(errs, &BuildError{Manipulation: b.manipulation, Err: err})
+	}
+	return append(errs, b.clauseErrors()...)
+}
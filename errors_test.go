@@ -0,0 +1,215 @@
+package sqlol
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestBuilder_Err(t *testing.T) {
+	PanicOnError = false
+	defer func() { PanicOnError = true }()
+
+	tests := []struct {
+		name string
+		b    *Builder
+		want string
+	}{
+		{"select", NewBuilder().Select(""), "sqlol: table is required in SELECT"},
+		{"insert", NewBuilder().Insert("a.user"), "sqlol: values are required in INSERT"},
+		{"update", NewBuilder().Update("a.user").Equal("id", 1), "sqlol: values are required in UPDATE"},
+		{"delete", NewBuilder().Delete("a.user"), "sqlol: deleting condition is required in DELETE"},
+	}
+	for _, tt := range tests {
+		if sql := tt.b.Build(); sql != "" {
+			t.Errorf("%s: Build() = %v, want empty", tt.name, sql)
+		}
+		if err := tt.b.Err(); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: Err() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	b := NewBuilder().Select("a.user")
+	if sql := b.Build(); sql == "" || b.Err() != nil {
+		t.Errorf("Build() = %v, Err() = %v", sql, b.Err())
+	}
+}
+
+func TestBuilder_BuildBatchErr(t *testing.T) {
+	PanicOnError = false
+	defer func() { PanicOnError = true }()
+
+	b := NewBuilder().Dialect(MySQL).Insert("user").Cols("Name").
+		Values([]User{{Name: "a"}, {Name: "b"}, {Name: "c"}}).
+		OnConflictDoNothing()
+	if got := b.BuildBatch(2); got != nil {
+		t.Errorf("BuildBatch() = %v, want nil", got)
+	}
+	if err := b.Err(); !errors.Is(err, errConflictDialect) {
+		t.Errorf("Err() = %v, want %v", err, errConflictDialect)
+	}
+}
+
+func TestBuilder_BuildCountENotSelect(t *testing.T) {
+	sql, err := NewBuilder().Update("a.user").Set("name = 'a'").BuildCountE()
+	if sql != "" || !errors.Is(err, ErrNotSelect) {
+		t.Errorf("BuildCountE() = %v, %v, want %v", sql, err, ErrNotSelect)
+	}
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || buildErr.Manipulation != "UPDATE" {
+		t.Errorf("BuildCountE() error = %#v, want *BuildError in UPDATE", err)
+	}
+}
+
+func TestBuilder_BuildCountEstimateE(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *Builder
+		want error
+	}{
+		{"sub query", NewBuilder().SelectSubQuery("SELECT * FROM a.user"), ErrEstimateSubQuery},
+		{"where", NewBuilder().Select("a.user").Equal("id", 1), ErrEstimateFiltered},
+		{"join", NewBuilder().Select("a.user").Alias("u").LeftJoin("a.order", "o", "o.user_id = u.id"), ErrEstimateFiltered},
+	}
+	for _, tt := range tests {
+		if _, err := tt.b.BuildCountEstimateE(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: BuildCountEstimateE() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestBuilder_HelperErr(t *testing.T) {
+	PanicOnError = false
+	defer func() { PanicOnError = true }()
+
+	b := NewBuilder().Select("")
+	if sql := b.BuildCountEstimate(); sql != "" || !errors.Is(b.Err(), ErrNoTable) {
+		t.Errorf("BuildCountEstimate() = %v, Err() = %v", sql, b.Err())
+	}
+
+	lookup := &ConditionBuilder{}
+	lookup.Equal("name", "a")
+	b = NewBuilder().Insert("a.user").Cols("Name")
+	if sql := b.BuildInsertOrGet(lookup); sql != "" || !errors.Is(b.Err(), ErrNoValues) {
+		t.Errorf("BuildInsertOrGet() = %v, Err() = %v", sql, b.Err())
+	}
+	for _, lookup := range []*ConditionBuilder{nil, {}} {
+		b = NewBuilder().Insert("a.user").Cols("Name").Values(User{Name: "a"})
+		if sql := b.BuildInsertOrGet(lookup); sql != "" || !errors.Is(b.Err(), ErrNoLookup) {
+			t.Errorf("BuildInsertOrGet() = %v, Err() = %v", sql, b.Err())
+		}
+	}
+
+	b = NewBuilder().Insert("a.user").Values([]User{})
+	if sql := b.BuildCopy(); sql != "" || !errors.Is(b.Err(), ErrNoCols) {
+		t.Errorf("BuildCopy() = %v, Err() = %v", sql, b.Err())
+	}
+
+	b = NewBuilder().Insert("").Cols("Name")
+	insert, updates := b.PartitionedUpsert([]User{{Id: 1, Name: "a"}, {Name: "b"}},
+		func(v interface{}) bool { return v.(User).Id > 0 })
+	if insert != "" || updates != nil || !errors.Is(b.Err(), ErrNoTable) {
+		t.Errorf("PartitionedUpsert() = %v, %v, Err() = %v", insert, updates, b.Err())
+	}
+
+	b = NewBuilder().Insert("a.user").Cols("Name").PrimaryKey("user_id")
+	insert, updates = b.PartitionedUpsert([]User{{Id: 1, Name: "a"}},
+		func(v interface{}) bool { return true })
+	if insert != "" || updates != nil || !errors.Is(b.Err(), ErrNoKeyField) {
+		t.Errorf("PartitionedUpsert() = %v, %v, Err() = %v", insert, updates, b.Err())
+	}
+}
+
+type testPanicking struct{}
+
+func TestBuilder_BuildERuntimePanic(t *testing.T) {
+	typ := reflect.TypeOf(testPanicking{})
+	RegisterType(typ, func(interface{}) string {
+		var m map[string]string
+		m["a"] = "b"
+		return ""
+	})
+	defer RegisterType(typ, nil)
+	defer func() {
+		if _, ok := recover().(runtime.Error); !ok {
+			t.Errorf("BuildE() should re-panic runtime errors")
+		}
+	}()
+	_, err := NewBuilder().Select("a.user").Equal("a", testPanicking{}).BuildE()
+	t.Errorf("BuildE() error = %v, want panic", err)
+}
+
+func TestBuilder_Errors(t *testing.T) {
+	b := NewBuilder().Insert("").GroupBy("id")
+	b.ConditionBuilder.BeginGroup()
+	var got []string
+	for _, err := range b.Errors() {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"sqlol: table is required in INSERT",
+		"sqlol: values are required in INSERT",
+		"sqlol: condition group is not ended in INSERT",
+		"sqlol: GroupBy is not allowed in INSERT",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Errors() = %v, want %v", got, want)
+	}
+	var buildErr *BuildError
+	if errs := b.Errors(); !errors.As(errs[0], &buildErr) || !errors.Is(errs[0], ErrNoTable) {
+		t.Errorf("Errors()[0] = %#v, want *BuildError wrapping %v", errs[0], ErrNoTable)
+	}
+	if errs := NewBuilder().Select("a.user").Errors(); errs != nil {
+		t.Errorf("Errors() = %v, want nil", errs)
+	}
+}
+
+func TestBuilder_BuildE(t *testing.T) {
+	tests := []struct {
+		b            *Builder
+		want         error
+		manipulation string
+	}{
+		{NewBuilder().Select(""), ErrNoTable, "SELECT"},
+		{NewBuilder().Insert("a.user"), ErrNoValues, "INSERT"},
+		{NewBuilder().Delete("a.user"), ErrNoDeleteCondition, "DELETE"},
+	}
+	for _, tt := range tests {
+		sql, err := tt.b.BuildE()
+		if sql != "" || !errors.Is(err, tt.want) {
+			t.Errorf("BuildE() = %v, %v, want %v", sql, err, tt.want)
+		}
+		var buildErr *BuildError
+		if !errors.As(err, &buildErr) || buildErr.Manipulation != tt.manipulation {
+			t.Errorf("BuildE() error = %#v, want manipulation %v", err, tt.manipulation)
+		}
+	}
+	if _, err := NewBuilder().Select("").BuildCountE(); !errors.Is(err, ErrNoTable) {
+		t.Errorf("BuildCountE() error = %v, want %v", err, ErrNoTable)
+	}
+	if sql, err := NewBuilder().Select("a.user").BuildE(); err != nil || trimSQL(sql) != "SELECT * FROM a.user" {
+		t.Errorf("BuildE() = %v, %v", sql, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Build() should panic")
+		}
+	}()
+	NewBuilder().Select("").Build()
+}
+
+func TestBuilder_ReadOnly(t *testing.T) {
+	if sql, err := NewBuilder().ReadOnly().Select("a.user").BuildE(); err != nil || sql == "" {
+		t.Errorf("BuildE() = %v, %v", sql, err)
+	}
+	_, err := NewBuilder().ReadOnly().Insert("a.user").Values(User{Name: "a"}).BuildE()
+	if !errors.Is(err, ErrReadOnly) {
+		t.Errorf("BuildE() error = %v, want %v", err, ErrReadOnly)
+	}
+	del := NewBuilder().Delete("a.user").Equal("id", 1).Returning("*")
+	_, err = NewBuilder().ReadOnly().With("d", del).Select("d").BuildE()
+	if !errors.Is(err, ErrReadOnly) {
+		t.Errorf("BuildE() error = %v, want %v", err, ErrReadOnly)
+	}
+}
@@ -0,0 +1,173 @@
+package sqlol
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+// 查询结果集，*sql.Rows满足该接口
+type Rows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Columns() ([]string, error)
+	Err() error
+	Close() error
+}
+
+// 单行结果
+type Row interface {
+	Scan(dest ...interface{}) error
+}
+
+// 执行查询，*sql.DB、*sql.Tx可以通过SQLQueryer转换
+type Queryer interface {
+	Query(query string, args ...interface{}) (Rows, error)
+}
+
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type sqlQueryer struct {
+	db sqlQuerier
+}
+
+// 将*sql.DB、*sql.Tx等转换为Queryer
+func SQLQueryer(db sqlQuerier) Queryer {
+	return sqlQueryer{db: db}
+}
+
+func (q sqlQueryer) Query(query string, args ...interface{}) (Rows, error) {
+	return q.db.Query(query, args...)
+}
+
+// 执行带RETURNING的语句，逐行回调fn，不会一次性把所有返回行加载到内存，
+// fn返回错误时停止遍历并返回该错误
+func (b *Builder) ExecReturningEach(db Queryer, fn func(row Row) error) error {
+	if len(b.returning) == 0 {
+		return errors.New("sqlol: RETURNING is required")
+	}
+	sql, args, err := b.buildArgs()
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// 分批执行带RETURNING的批量插入，每批最多chunkSize行，
+// 所有批次返回的行按列名追加到dest中，dest为结构体(或结构体指针)切片的指针，
+// 列名按CamelToSnake与字段名匹配，未匹配的列被忽略
+func (b *Builder) ExecBatchReturning(db Queryer, chunkSize int, dest interface{}) error {
+	if len(b.returning) == 0 {
+		return errors.New("sqlol: RETURNING is required")
+	}
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return errors.New("sqlol: dest must be a pointer to slice")
+	}
+	slice = slice.Elem()
+	for _, chunk := range b.chunks(chunkSize) {
+		sql, args, err := chunk.buildArgs()
+		if err != nil {
+			return err
+		}
+		if sql == "" {
+			continue
+		}
+		rows, err := db.Query(sql, args...)
+		if err != nil {
+			return err
+		}
+		err = appendRows(rows, slice)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 执行INSERT ... ON CONFLICT语句，统计新插入与冲突后更新的行数，
+// 原有的RETURNING会被替换为插入标记列；DO NOTHING跳过的行不会返回，不计入任何一项
+func (b *Builder) ExecUpsertCounts(db Queryer) (inserted, updated int, err error) {
+	if b.manipulation != manipulationInsert {
+		return 0, 0, errors.New("sqlol: upsert counts require an INSERT")
+	}
+	upsert := b.Clone()
+	upsert.returning = []string{insertFlag}
+	sql, args, err := upsert.buildArgs()
+	if err != nil {
+		return 0, 0, err
+	}
+	if sql == "" {
+		return 0, 0, nil
+	}
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var isInsert bool
+		if err := rows.Scan(&isInsert); err != nil {
+			return 0, 0, err
+		}
+		if isInsert {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	return inserted, updated, rows.Err()
+}
+
+// 将rows的所有行扫描为slice的元素并追加到slice中
+func appendRows(rows Rows, slice reflect.Value) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return errors.New("sqlol: dest must be a slice of struct")
+	}
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		dest := make([]interface{}, len(columns))
+		for i, column := range columns {
+			column := column
+			field := elem.Elem().FieldByNameFunc(func(name string) bool {
+				return CamelToSnake(name) == column
+			})
+			if field.IsValid() && field.CanSet() {
+				dest[i] = field.Addr().Interface()
+			} else {
+				dest[i] = new(interface{})
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,172 @@
+package sqlol
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type fakeRows struct {
+	columns []string
+	rows    [][]interface{}
+	index   int
+	closed  bool
+}
+
+func (r *fakeRows) Next() bool {
+	if r.index >= len(r.rows) {
+		return false
+	}
+	r.index++
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	row := r.rows[r.index-1]
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destination arguments, got %d", len(row), len(dest))
+	}
+	for i, v := range row {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (r *fakeRows) Columns() ([]string, error) { return r.columns, nil }
+func (r *fakeRows) Err() error                 { return nil }
+func (r *fakeRows) Close() error               { r.closed = true; return nil }
+
+type fakeQueryer struct {
+	queries []string
+	args    [][]interface{}
+	results []*fakeRows
+}
+
+func (q *fakeQueryer) Query(query string, args ...interface{}) (Rows, error) {
+	q.queries = append(q.queries, query)
+	q.args = append(q.args, args)
+	if len(q.results) == 0 {
+		return nil, errors.New("no result")
+	}
+	rows := q.results[0]
+	q.results = q.results[1:]
+	return rows, nil
+}
+
+func TestBuilder_ExecReturningEach(t *testing.T) {
+	rows := &fakeRows{
+		columns: []string{"id"},
+		rows:    [][]interface{}{{int64(1)}, {int64(2)}, {int64(3)}},
+	}
+	db := &fakeQueryer{results: []*fakeRows{rows}}
+	var ids []int64
+	err := NewBuilder().Update("a.user").
+		Set("age = age + 1").
+		Equal("name", "a").
+		Returning("id").
+		ExecReturningEach(db, func(row Row) error {
+			var id int64
+			if err := row.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ExecReturningEach() error = %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Errorf("ExecReturningEach() ids = %v", ids)
+	}
+	if got, want := trimSQL(db.queries[0]),
+		"UPDATE a.user SET age = age + 1 WHERE (name = $1) RETURNING id"; got != want {
+		t.Errorf("ExecReturningEach() query = %v, want %v", got, want)
+	}
+	if !rows.closed {
+		t.Errorf("ExecReturningEach() should close rows")
+	}
+}
+
+func TestBuilder_ExecReturningEachStop(t *testing.T) {
+	rows := &fakeRows{columns: []string{"id"}, rows: [][]interface{}{{int64(1)}, {int64(2)}}}
+	stop := errors.New("stop")
+	calls := 0
+	err := NewBuilder().Delete("a.user").Equal("id", 1).Returning("id").
+		ExecReturningEach(&fakeQueryer{results: []*fakeRows{rows}}, func(row Row) error {
+			calls++
+			return stop
+		})
+	if err != stop || calls != 1 {
+		t.Errorf("ExecReturningEach() error = %v, calls = %d", err, calls)
+	}
+}
+
+func TestBuilder_ExecBatchReturning(t *testing.T) {
+	type user struct {
+		Id   int64
+		Name string
+	}
+	db := &fakeQueryer{results: []*fakeRows{
+		{columns: []string{"id", "name"}, rows: [][]interface{}{{int64(1), "a"}, {int64(2), "b"}}},
+		{columns: []string{"id", "name"}, rows: [][]interface{}{{int64(3), "c"}}},
+	}}
+	var got []user
+	err := NewBuilder().Insert("a.user").Cols("Name").
+		Values([]user{{Name: "a"}, {Name: "b"}, {Name: "c"}}).
+		Returning("id", "name").
+		ExecBatchReturning(db, 2, &got)
+	if err != nil {
+		t.Fatalf("ExecBatchReturning() error = %v", err)
+	}
+	want := []user{{1, "a"}, {2, "b"}, {3, "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExecBatchReturning() = %v, want %v", got, want)
+	}
+	wantQueries := []string{
+		"INSERT INTO a.user(name) VALUES ($1),($2) RETURNING id,name",
+		"INSERT INTO a.user(name) VALUES ($1) RETURNING id,name",
+	}
+	for i, query := range db.queries {
+		if got := trimSQL(query); got != wantQueries[i] {
+			t.Errorf("ExecBatchReturning() query %d = %v, want %v", i, got, wantQueries[i])
+		}
+	}
+	if len(db.queries) != 2 {
+		t.Errorf("ExecBatchReturning() queries = %d, want 2", len(db.queries))
+	}
+	wantArgs := [][]interface{}{{"a", "b"}, {"c"}}
+	if !reflect.DeepEqual(db.args, wantArgs) {
+		t.Errorf("ExecBatchReturning() args = %v, want %v", db.args, wantArgs)
+	}
+}
+
+func TestBuilder_ExecUpsertCounts(t *testing.T) {
+	rows := &fakeRows{
+		columns: []string{"sqlolinserted"},
+		rows:    [][]interface{}{{true}, {false}, {true}, {true}},
+	}
+	db := &fakeQueryer{results: []*fakeRows{rows}}
+	inserted, updated, err := NewBuilder().Insert("a.user").Cols("Name", "Remark").
+		Values([]User{{Name: "a", Remark: "b"}, {Name: "c", Remark: "d"}}).
+		OnConflictDoUpdate([]string{"name"}, "remark").
+		Returning("id").
+		ExecUpsertCounts(db)
+	if err != nil {
+		t.Fatalf("ExecUpsertCounts() error = %v", err)
+	}
+	if inserted != 3 || updated != 1 {
+		t.Errorf("ExecUpsertCounts() = %d, %d, want 3, 1", inserted, updated)
+	}
+	if !rows.closed {
+		t.Error("rows not closed")
+	}
+	want := "INSERT INTO a.user(name,remark) VALUES ($1,$2),($3,$4) " +
+		"ON CONFLICT (name) DO UPDATE SET remark = EXCLUDED.remark RETURNING (xmax = 0) AS sqlolinserted"
+	if got := trimSQL(db.queries[0]); got != want {
+		t.Errorf("ExecUpsertCounts() sql = %v, want %v", got, want)
+	}
+	if _, _, err := NewBuilder().Update("a.user").Set("age = 1").ExecUpsertCounts(db); err == nil {
+		t.Error("ExecUpsertCounts() expected error for UPDATE")
+	}
+}
@@ -0,0 +1,130 @@
+package sqlol
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// date_trunc表达式，如 date_trunc('day', created_at) AS day，alias为空时不加别名
+func DateTrunc(unit, col, alias string) string {
+	return withAlias(fmt.Sprintf("date_trunc(%s, %s)", String(unit), col), alias)
+}
+
+// EXTRACT表达式，如 EXTRACT(YEAR FROM created_at) AS year，alias为空时不加别名
+func Extract(field, col, alias string) string {
+	return withAlias(fmt.Sprintf("EXTRACT(%s FROM %s)", strings.ToUpper(field), col), alias)
+}
+
+func withAlias(expr, alias string) string {
+	if alias == "" {
+		return expr
+	}
+	return expr + " AS " + alias
+}
+
+// VALUES列表表达式，如 (VALUES (1,'a'),(2,'b')) AS t(id,name)，
+// 可用于Select或Join(此时as传空)
+func ValuesTable(alias string, cols []string, rows [][]interface{}) string {
+	var values []string
+	for _, row := range rows {
+		values = append(values, "("+sliceValue(row)+")")
+	}
+	return fmt.Sprintf("(VALUES %s) AS %s(%s)",
+		strings.Join(values, ","), alias, strings.Join(cols, ","))
+}
+
+// 连续百分位数，如 percentile_cont(0.5) WITHIN GROUP (ORDER BY amount) AS median
+func PercentileCont(fraction float64, orderCol string, alias string) string {
+	return orderedSetAggregate("percentile_cont", fraction, orderCol, alias)
+}
+
+// 离散百分位数，如 percentile_disc(0.9) WITHIN GROUP (ORDER BY amount) AS p90
+func PercentileDisc(fraction float64, orderCol string, alias string) string {
+	return orderedSetAggregate("percentile_disc", fraction, orderCol, alias)
+}
+
+// 众数，如 mode() WITHIN GROUP (ORDER BY status) AS status
+func Mode(orderCol, alias string) string {
+	return withAlias(fmt.Sprintf("mode() WITHIN GROUP (ORDER BY %s)", orderCol), alias)
+}
+
+func orderedSetAggregate(fn string, fraction float64, orderCol, alias string) string {
+	if fraction < 0 || fraction > 1 {
+		log.Panic("sqlol: fraction must be between 0 and 1")
+	}
+	return withAlias(fmt.Sprintf("%s(%s) WITHIN GROUP (ORDER BY %s)",
+		fn, strconv.FormatFloat(fraction, 'f', -1, 64), orderCol), alias)
+}
+
+// 聚合表达式，如 COUNT(DISTINCT user_id) AS users
+type Aggregate struct {
+	fn       string
+	expr     string
+	alias    string
+	distinct bool
+	window   *window
+}
+
+type window struct {
+	partitionBy []string
+	orderBy     []string
+}
+
+func Count(expr, alias string) Aggregate {
+	return Aggregate{fn: "COUNT", expr: expr, alias: alias}
+}
+
+func Sum(expr, alias string) Aggregate {
+	return Aggregate{fn: "SUM", expr: expr, alias: alias}
+}
+
+func Avg(expr, alias string) Aggregate {
+	return Aggregate{fn: "AVG", expr: expr, alias: alias}
+}
+
+func Min(expr, alias string) Aggregate {
+	return Aggregate{fn: "MIN", expr: expr, alias: alias}
+}
+
+func Max(expr, alias string) Aggregate {
+	return Aggregate{fn: "MAX", expr: expr, alias: alias}
+}
+
+// 只对不重复的值聚合，如 COUNT(DISTINCT user_id)，与查询级别的DISTINCT无关
+func (a Aggregate) Distinct() Aggregate {
+	a.distinct = true
+	return a
+}
+
+// 作为窗口函数，如 SUM(amount) OVER (PARTITION BY user_id ORDER BY created_at)，
+// partitionBy、orderBy均可为空
+func (a Aggregate) Over(partitionBy []string, orderBy ...string) Aggregate {
+	a.window = &window{partitionBy: partitionBy, orderBy: orderBy}
+	return a
+}
+
+func (a Aggregate) String() string {
+	expr := a.expr
+	if a.distinct {
+		expr = "DISTINCT " + expr
+	}
+	expr = fmt.Sprintf("%s(%s)", a.fn, expr)
+	if a.window != nil {
+		var clauses []string
+		if len(a.window.partitionBy) > 0 {
+			clauses = append(clauses, "PARTITION BY "+strings.Join(a.window.partitionBy, ","))
+		}
+		if len(a.window.orderBy) > 0 {
+			clauses = append(clauses, "ORDER BY "+strings.Join(a.window.orderBy, ","))
+		}
+		expr += " OVER (" + strings.Join(clauses, " ") + ")"
+	}
+	return withAlias(expr, a.alias)
+}
+
+// 累计求和，如 SUM(amount) OVER (ORDER BY created_at) AS running_total
+func RunningTotal(expr, orderCol, alias string) string {
+	return Sum(expr, alias).Over(nil, orderCol).String()
+}
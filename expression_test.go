@@ -0,0 +1,80 @@
+package sqlol
+
+import "testing"
+
+func TestDateTrunc(t *testing.T) {
+	if got, want := DateTrunc("day", "created_at", "day"),
+		"date_trunc('day', created_at) AS day"; got != want {
+		t.Errorf("DateTrunc() = %v, want %v", got, want)
+	}
+	if got, want := DateTrunc("month", "t.created_at", ""),
+		"date_trunc('month', t.created_at)"; got != want {
+		t.Errorf("DateTrunc() = %v, want %v", got, want)
+	}
+	if got, want := Extract("year", "created_at", "year"),
+		"EXTRACT(YEAR FROM created_at) AS year"; got != want {
+		t.Errorf("Extract() = %v, want %v", got, want)
+	}
+}
+
+func TestValuesTable(t *testing.T) {
+	got := ValuesTable("t", []string{"id", "name"}, [][]interface{}{{1, "a"}, {2, "it's"}})
+	want := "(VALUES (1,'a'),(2,'it''s')) AS t(id,name)"
+	if got != want {
+		t.Errorf("ValuesTable() = %v, want %v", got, want)
+	}
+
+	sql := NewBuilder().Select("a.user").Alias("u").
+		InnerJoin(ValuesTable("t", []string{"id"}, [][]interface{}{{1}, {2}}), "", "t.id = u.id").
+		Build()
+	want = "SELECT * FROM a.user AS u INNER JOIN (VALUES (1),(2)) AS t(id) ON t.id = u.id"
+	if got := trimSQL(sql); got != want {
+		t.Errorf("Build() = %v, want %v", got, want)
+	}
+}
+
+func TestOrderedSetAggregate(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{PercentileCont(0.5, "amount", "median"),
+			"percentile_cont(0.5) WITHIN GROUP (ORDER BY amount) AS median"},
+		{PercentileCont(1, "amount DESC", ""),
+			"percentile_cont(1) WITHIN GROUP (ORDER BY amount DESC)"},
+		{PercentileDisc(0.95, "t.amount", "p95"),
+			"percentile_disc(0.95) WITHIN GROUP (ORDER BY t.amount) AS p95"},
+		{Mode("status", "status"),
+			"mode() WITHIN GROUP (ORDER BY status) AS status"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %v, want %v", tt.got, tt.want)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{Count("1", "").String(), "COUNT(1)"},
+		{Count("user_id", "users").Distinct().String(), "COUNT(DISTINCT user_id) AS users"},
+		{Sum("amount", "total").Distinct().String(), "SUM(DISTINCT amount) AS total"},
+		{Sum("amount", "total").String(), "SUM(amount) AS total"},
+		{Avg("age", "avg_age").String(), "AVG(age) AS avg_age"},
+		{Max("age", "").String(), "MAX(age)"},
+		{Count("1", "n").Over([]string{"user_id"}).String(), "COUNT(1) OVER (PARTITION BY user_id) AS n"},
+		{Sum("amount", "").Over([]string{"user_id"}, "created_at", "id").String(),
+			"SUM(amount) OVER (PARTITION BY user_id ORDER BY created_at,id)"},
+		{Avg("amount", "").Over(nil).String(), "AVG(amount) OVER ()"},
+		{RunningTotal("amount", "created_at", "running_total"),
+			"SUM(amount) OVER (ORDER BY created_at) AS running_total"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %v, want %v", tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package sqlol
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+var (
+	stringLiteralRegexp  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteralRegexp  = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?\b`)
+	booleanLiteralRegexp = regexp.MustCompile(`\b(?:true|false)\b`)
+	literalListRegexp    = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+	// 负数的负号：前面(忽略空白)不是字段、右括号、字面值等操作数，如 = -1、IN (-1, -2)、* -2
+	unaryMinusRegexp = regexp.MustCompile(`(^|[^\w\s)."'?])(\s*)-\?`)
+)
+
+// 生成sql结构的指纹，忽略字面值(字符串、数字、布尔值、IN列表长度)，
+// 类似pg_stat_statements的归一化，用于监控中对相似查询归类
+func (b *Builder) Fingerprint() string {
+	sum := sha1.Sum([]byte(normalizeSQL(b.Build())))
+	return hex.EncodeToString(sum[:])
+}
+
+// 生成完整sql(包括字面值)的哈希，用于查询结果缓存的key；与Fingerprint不同，值不同的查询key也不同
+func (b *Builder) CacheKey() string {
+	sum := sha1.Sum([]byte(b.Build()))
+	return hex.EncodeToString(sum[:])
+}
+
+// 将sql中的字面值替换为?，并压缩空白
+func normalizeSQL(sql string) string {
+	sql = stringLiteralRegexp.ReplaceAllString(sql, "?")
+	sql = numberLiteralRegexp.ReplaceAllString(sql, "?")
+	sql = unaryMinusRegexp.ReplaceAllString(sql, "$1$2?")
+	sql = booleanLiteralRegexp.ReplaceAllString(sql, "?")
+	sql = literalListRegexp.ReplaceAllString(sql, "?")
+	return strings.Join(strings.Fields(sql), " ")
+}
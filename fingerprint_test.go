@@ -0,0 +1,54 @@
+package sqlol
+
+import "testing"
+
+func TestNormalizeSQL(t *testing.T) {
+	sql := NewBuilder().Select("a.user2").
+		Equal("name", "it's 1").In("id", []int{1, 2, 3}).
+		Equal("is_admin", true).Limit(10).Build()
+	want := "SELECT * FROM a.user2 WHERE (name = ?) AND (id IN (?)) AND (is_admin = ?) LIMIT ?"
+	if got := normalizeSQL(sql); got != want {
+		t.Errorf("normalizeSQL() = %v, want %v", got, want)
+	}
+
+	a := NewBuilder().Select("a.user").Equal("id", -1).In("age", []int{-1, 2}).Build()
+	b := NewBuilder().Select("a.user").Equal("id", 1).In("age", []int{1, 2}).Build()
+	if got, want := normalizeSQL(a), normalizeSQL(b); got != want {
+		t.Errorf("normalizeSQL() = %v, want %v", got, want)
+	}
+	if got, want := normalizeSQL("UPDATE a.user SET age = age -1, score = score - -2"),
+		"UPDATE a.user SET age = age -?, score = score - ?"; got != want {
+		t.Errorf("normalizeSQL() = %v, want %v", got, want)
+	}
+}
+
+func TestBuilder_Fingerprint(t *testing.T) {
+	a := NewBuilder().Select("a.user").Equal("name", "a").In("id", []int{1, 2}).Limit(10)
+	b := NewBuilder().Select("a.user").Equal("name", "b").In("id", []int{3, 4, 5}).Limit(20)
+	c := NewBuilder().Select("a.user").Equal("age", "a").In("id", []int{1, 2}).Limit(10)
+	d := NewBuilder().Select("a.user").Equal("name", "a").In("id", []int{1, 2})
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("Fingerprint() differs for queries differing only in values")
+	}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Errorf("Fingerprint() equal for queries on different fields")
+	}
+	if a.Fingerprint() == d.Fingerprint() {
+		t.Errorf("Fingerprint() equal for queries with different clauses")
+	}
+}
+
+func TestBuilder_CacheKey(t *testing.T) {
+	a := NewBuilder().Select("a.user").Equal("name", "a").In("id", []int{1, 2}).Limit(10)
+	b := NewBuilder().Select("a.user").Equal("name", "a").In("id", []int{1, 2}).Limit(10)
+	c := NewBuilder().Select("a.user").Equal("name", "b").In("id", []int{1, 2}).Limit(10)
+	if a.CacheKey() != b.CacheKey() {
+		t.Errorf("CacheKey() differs for identical queries")
+	}
+	if a.CacheKey() == c.CacheKey() {
+		t.Errorf("CacheKey() equal for queries with different values")
+	}
+	if a.Fingerprint() != c.Fingerprint() {
+		t.Errorf("Fingerprint() differs for queries differing only in values")
+	}
+}
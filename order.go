@@ -0,0 +1,94 @@
+package sqlol
+
+import "strings"
+
+// 结构化的排序项，如 created_at DESC NULLS LAST
+type orderItem struct {
+	expr  string
+	dir   string        // ASC、DESC或空(默认升序)
+	nulls string        // NULLS FIRST、NULLS LAST或空
+	args  []interface{} // expr中参数标记对应的值，生成时依次收集为参数
+}
+
+// 解析排序字符串，一个字符串中逗号分隔的多个排序项(括号、引号内的逗号除外)会被拆开
+func parseOrders(order string) []orderItem {
+	var items []orderItem
+	for _, part := range splitTopLevel(order) {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, parseOrder(part))
+		}
+	}
+	return items
+}
+
+func parseOrder(order string) orderItem {
+	var item orderItem
+	for _, nulls := range []string{"NULLS FIRST", "NULLS LAST"} {
+		if hasSuffixFold(order, " "+nulls) {
+			item.nulls = nulls
+			order = strings.TrimSpace(order[:len(order)-len(nulls)-1])
+			break
+		}
+	}
+	for _, dir := range []string{"ASC", "DESC"} {
+		if hasSuffixFold(order, " "+dir) {
+			item.dir = dir
+			order = strings.TrimSpace(order[:len(order)-len(dir)-1])
+			break
+		}
+	}
+	item.expr = order
+	return item
+}
+
+func hasSuffixFold(s, suffix string) bool {
+	return len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix)
+}
+
+// 按顶层逗号拆分，括号、方括号及单引号内的逗号不拆分
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start, quoted := 0, 0, false
+	for i, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// 反转方向，未指定方向视为ASC；NULL的位置随之反转，未指定时保持默认(即随方向反转)
+func (o orderItem) reverse() orderItem {
+	if o.dir == "DESC" {
+		o.dir = "ASC"
+	} else {
+		o.dir = "DESC"
+	}
+	switch o.nulls {
+	case "NULLS FIRST":
+		o.nulls = "NULLS LAST"
+	case "NULLS LAST":
+		o.nulls = "NULLS FIRST"
+	}
+	return o
+}
+
+func (o orderItem) String() string {
+	s := o.expr
+	if o.dir != "" {
+		s += " " + o.dir
+	}
+	if o.nulls != "" {
+		s += " " + o.nulls
+	}
+	return s
+}
@@ -38,10 +38,16 @@ func (t TryMultiLike) Execute(b *Builder) {
 type TryIn struct {
 	Field  string
 	Values interface{}
+	// 为true时剔除Values中的零值元素
+	OmitEmpty bool
 }
 
 func (t TryIn) Execute(b *Builder) {
-	b.TryIn(t.Field, t.Values)
+	if t.OmitEmpty {
+		b.TryInNonEmpty(t.Field, t.Values)
+	} else {
+		b.TryIn(t.Field, t.Values)
+	}
 }
 
 type TryTimeRange struct {
@@ -1,13 +1,17 @@
 package sqlol
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"log"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 func CamelsToSnakes(fields []string) (result []string) {
@@ -66,16 +70,178 @@ func String(s string) string {
 	return "'" + s + "'"
 }
 
+// 转义标识符，按.拆分后分别用双引号包裹，如 o.status => "o"."status"，
+// 与String对应，String用于值，Quote用于表名、字段名
+func Quote(ident string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		if part != "*" {
+			parts[i] = `"` + strings.Replace(part, `"`, `""`, -1) + `"`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// 标识符标记，添加条件、赋值时字段名以该标记包裹，生成sql时再决定是否转义，
+// tip: 与argMarker一样不会出现在字面值中(参数在标记替换之后才内联)
+const identMarker = "\x01"
+
+func markIdent(name string) string {
+	return identMarker + name + identMarker
+}
+
+// 去掉标识符标记，quote为true时按方言d转义其中的裸标识符
+func resolveIdents(sql string, quote bool, d Dialect) string {
+	if !strings.Contains(sql, identMarker) {
+		return sql
+	}
+	parts := strings.Split(sql, identMarker)
+	if quote {
+		for i := 1; i < len(parts); i += 2 {
+			parts[i] = quoteIdentifier(parts[i], d)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// 对裸标识符(如 id、a.user、u.*)按方言做转义，d为nil时按Postgres，
+// 函数调用、表达式、数字及已转义的标识符原样返回
+func quoteIdentifier(ident string, d Dialect) string {
+	if !isBareIdentifier(ident) {
+		return ident
+	}
+	if d == nil {
+		return Quote(ident)
+	}
+	return d.QuoteIdentifier(ident)
+}
+
+func isBareIdentifier(ident string) bool {
+	for _, part := range strings.Split(ident, ".") {
+		if part == "*" {
+			continue
+		}
+		if part == "" {
+			return false
+		}
+		for i, r := range part {
+			if r == '_' || unicode.IsLetter(r) || (i > 0 && (r == '$' || unicode.IsDigit(r))) {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// 精确的数值，如金额，ToString原样生成不带引号的数值，如 Numeric("123.45") => 123.45，
+// 避免float64的精度损失，值不是合法的数值时panic
+type Numeric string
+
+var numericRegexp = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// 识别Numeric，返回数值字符串；decimal.Decimal等实现了driver.Valuer的类型由valuer处理，
+// Value()为数值字符串时同样不加引号
+func numericString(i interface{}) (string, bool) {
+	v, ok := i.(Numeric)
+	if !ok {
+		return "", false
+	}
+	if !numericRegexp.MatchString(string(v)) {
+		log.Panicf("sqlol: invalid numeric %q", string(v))
+	}
+	return string(v), true
+}
+
+// database/sql的Null*类型，无效时为NULL，有效时按其中的值转换，
+// 避免经Valuer转换后数字字符串不加引号、时间格式不一致等问题
+func nullString(i interface{}, quote func(string) string) (string, bool) {
+	if v := reflect.ValueOf(i); v.Kind() == reflect.Ptr && !v.IsNil() {
+		i = v.Elem().Interface()
+	}
+	var valid bool
+	var value interface{}
+	switch v := i.(type) {
+	case sql.NullString:
+		valid, value = v.Valid, v.String
+	case sql.NullInt64:
+		valid, value = v.Valid, v.Int64
+	case sql.NullInt32:
+		valid, value = v.Valid, v.Int32
+	case sql.NullFloat64:
+		valid, value = v.Valid, v.Float64
+	case sql.NullBool:
+		valid, value = v.Valid, v.Bool
+	case sql.NullTime:
+		valid, value = v.Valid, v.Time
+	default:
+		return "", false
+	}
+	if !valid {
+		return "NULL", true
+	}
+	return literal(value, quote), true
+}
+
+// 时间间隔值，ToString生成 INTERVAL '1 day'，可直接用于Equal、Gt、Lt、Between等条件，
+// 参数化时也以字面值生成，不作为参数绑定
+type Interval string
+
+var typeFormatters = struct {
+	sync.RWMutex
+	m map[reflect.Type]func(interface{}) string
+}{m: make(map[reflect.Type]func(interface{}) string)}
+
+// 注册自定义类型的字面值生成函数，如 decimal.Decimal、uuid.UUID，ToString优先使用，
+// fn返回的是可直接拼接到sql中的字面值，需要自行转义(如使用String)；
+// 通常在init中注册，可并发读取；fn为nil时取消注册。BuildArgs绑定的参数不受影响
+func RegisterType(t reflect.Type, fn func(interface{}) string) {
+	typeFormatters.Lock()
+	defer typeFormatters.Unlock()
+	if fn == nil {
+		delete(typeFormatters.m, t)
+	} else {
+		typeFormatters.m[t] = fn
+	}
+}
+
+func typeFormatter(i interface{}) func(interface{}) string {
+	typeFormatters.RLock()
+	defer typeFormatters.RUnlock()
+	if len(typeFormatters.m) == 0 || i == nil {
+		return nil
+	}
+	return typeFormatters.m[reflect.TypeOf(i)]
+}
+
 func ToString(i interface{}) string {
+	return literal(i, String)
+}
+
+// 生成字面值，字符串经quote转义，quote由方言决定(见Dialect.QuoteString)
+func literal(i interface{}, quote func(string) string) string {
+	if fn := typeFormatter(i); fn != nil {
+		return fn(i)
+	}
 	// special types
 	switch v := i.(type) {
-	case []byte:
-		return string(v)
+	case Interval:
+		return "INTERVAL " + quote(string(v))
 	case time.Time:
 		// postgres all time type has 1 microsecond resolution.
 		return "'" + v.Format("2006-01-02T15:04:05.999999Z07:00") + "'"
+	}
+	if str, ok := nullString(i, quote); ok {
+		return str
+	}
+	if str, ok := numericString(i); ok {
+		return str
+	}
+	switch v := i.(type) {
+	case []byte:
+		return string(v)
 	case driver.Valuer:
-		return valuer(v)
+		return valuer(v, quote)
 	case nil:
 		return "NULL"
 	}
@@ -84,7 +250,7 @@ func ToString(i interface{}) string {
 	v := reflect.ValueOf(i)
 	switch v.Kind() {
 	case reflect.String:
-		return String(v.String())
+		return quote(v.String())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return strconv.FormatInt(v.Int(), 10)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -103,25 +269,88 @@ func ToString(i interface{}) string {
 		if v.IsNil() {
 			return "NULL"
 		} else {
-			return ToString(v.Elem().Interface())
+			return literal(v.Elem().Interface(), quote)
 		}
 	}
 
 	// other types: use json
-	return JsonString(i)
+	return jsonLiteral(i, quote)
+}
+
+// 生成Postgres数组字面值，如 []string{"a","b,c"} => '{"a","b,c"}'，
+// 字符串元素用双引号包裹，其中的双引号、反斜杠会转义，nil元素为NULL，支持多维数组；
+// 切片直接传给ToString时生成的是JSON数组 '["a","b,c"]'，只适用于json/jsonb字段
+func ArrayString(values interface{}) string {
+	return String(arrayLiteral(values))
+}
+
+// 数组字段的值，ToString生成与ArrayString相同的字面值，参数化时绑定为 {a,b} 文本，
+// 如 Equal("tags", AsArray([]string{"a","b"}))、结构体字段类型为Array时的插入
+type Array struct {
+	Values interface{}
+}
+
+func AsArray(values interface{}) Array {
+	return Array{Values: values}
+}
+
+func (a Array) Value() (driver.Value, error) {
+	if a.Values == nil {
+		return nil, nil
+	}
+	return arrayLiteral(a.Values), nil
+}
+
+func arrayLiteral(values interface{}) string {
+	args := sliceArgs(values)
+	elements := make([]string, len(args))
+	for i, arg := range args {
+		elements[i] = arrayElement(arg)
+	}
+	return "{" + strings.Join(elements, ",") + "}"
+}
+
+var arrayElementReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func arrayElement(arg interface{}) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return `"` + v.Format("2006-01-02T15:04:05.999999Z07:00") + `"`
+	case []byte:
+		return `"` + arrayElementReplacer.Replace(string(v)) + `"`
+	}
+	v := reflect.ValueOf(arg)
+	switch v.Kind() {
+	case reflect.String:
+		return `"` + arrayElementReplacer.Replace(v.String()) + `"`
+	case reflect.Slice, reflect.Array:
+		return arrayLiteral(arg)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "NULL"
+		}
+		return arrayElement(v.Elem().Interface())
+	}
+	return ToString(arg)
 }
 
 func JsonString(data interface{}) string {
+	return jsonLiteral(data, String)
+}
+
+func jsonLiteral(data interface{}, quote func(string) string) string {
 	b, err := json.Marshal(data)
 	if err != nil {
 		log.Panic("sqlol json.Marshal: ", err)
 	}
-	return String(string(b))
+	return quote(string(b))
 }
 
 var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
 
-func valuer(v driver.Valuer) string {
+func valuer(v driver.Valuer, quote func(string) string) string {
 	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() &&
 		rv.Type().Elem().Implements(valuerType) {
 		return "NULL"
@@ -136,36 +365,83 @@ func valuer(v driver.Valuer) string {
 		if _, err := strconv.ParseFloat(s, 64); err == nil {
 			return s
 		} else {
-			return String(s)
+			return quote(s)
 		}
 	default:
-		return ToString(ifc)
+		return literal(ifc, quote)
 	}
 }
 
 func sliceValue(values interface{}) string {
+	var s []string
+	for _, arg := range sliceArgs(values) {
+		s = append(s, ToString(arg))
+	}
+	return strings.Join(s, ",")
+}
+
+// 返回array/slice中的元素，values不是array/slice时返回nil
+func sliceArgs(values interface{}) []interface{} {
 	if values == nil {
-		return ""
+		return nil
 	}
 	v := reflect.ValueOf(values)
 	kind := v.Kind()
 	if kind != reflect.Array && kind != reflect.Slice {
-		return ""
+		return nil
 	}
 	vLen := v.Len()
-	if vLen == 0 {
-		return ""
-	}
-	var s []string
+	var args []interface{}
 	for i := 0; i < vLen; i++ {
-		s = append(s, ToString(v.Index(i).Interface()))
+		args = append(args, v.Index(i).Interface())
 	}
-	return strings.Join(s, ",")
+	return args
+}
+
+// 与sliceArgs相同，但会剔除其中的NULL元素，并返回是否包含NULL
+func nullableSliceArgs(values interface{}) ([]interface{}, bool) {
+	var args []interface{}
+	hasNull := false
+	for _, arg := range sliceArgs(values) {
+		if ToString(arg) == "NULL" {
+			hasNull = true
+		} else {
+			args = append(args, arg)
+		}
+	}
+	return args, hasNull
+}
+
+// 返回array/slice中的非零值元素，values不是array/slice时返回nil
+func nonEmptyElements(values interface{}) []interface{} {
+	if values == nil {
+		return nil
+	}
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Array && v.Kind() != reflect.Slice {
+		return nil
+	}
+	var result []interface{}
+	for i := 0; i < v.Len(); i++ {
+		if elem := v.Index(i).Interface(); !isEmpty(elem) {
+			result = append(result, elem)
+		}
+	}
+	return result
 }
 
 func isEmpty(value interface{}) bool {
+	// tip: 带时区的零值time.Time与time.Time{}不相等，需要用IsZero判断
+	switch t := value.(type) {
+	case time.Time:
+		return t.IsZero()
+	case *time.Time:
+		return t == nil || t.IsZero()
+	}
 	v := reflect.ValueOf(value)
 	switch v.Kind() {
+	case reflect.Invalid:
+		return true
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
 		return v.Len() == 0
 	case reflect.Bool:
@@ -232,35 +508,75 @@ func structExportedFields(t reflect.Type) (fields []string) {
 }
 
 func StructValues(data interface{}, fields []string) string {
+	sql, args := structValueArgs(data, fields)
+	return inlineArgs(sql, args, nil)
+}
+
+// 与StructValues相同，但值使用参数标记，并按顺序返回参数
+func structValueArgs(data interface{}, fields []string) (string, []interface{}) {
 	value := reflect.ValueOf(data)
 	switch value.Kind() {
 	case reflect.Slice, reflect.Array:
 		var slice []string
+		var args []interface{}
 		for i := 0; i < value.Len(); i++ {
-			slice = append(slice, structValues(value.Index(i), fields))
+			row, rowArgs := structValues(value.Index(i), fields)
+			slice = append(slice, row)
+			args = append(args, rowArgs...)
 		}
-		return strings.Join(slice, ",")
+		return strings.Join(slice, ","), args
 	default:
 		return structValues(value, fields)
 	}
 }
 
-func structValues(value reflect.Value, fields []string) string {
+func structValues(value reflect.Value, fields []string) (string, []interface{}) {
 	if value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
 		value = value.Elem()
 	}
 	if value.Kind() != reflect.Struct {
 		log.Panic("sqlol: data must be struct or struct slice.")
 	}
-	var slice []string
+	var args []interface{}
 	for _, fieldName := range fields {
 		field := structField(value, fieldName)
 		if !field.IsValid() {
 			log.Panic("sqlol: no field '" + fieldName + "' in struct")
 		}
-		slice = append(slice, ToString(field.Interface()))
+		args = append(args, field.Interface())
 	}
-	return "(" + strings.Join(slice, ",") + ")"
+	return "(" + argList(len(args)) + ")", args
+}
+
+// 生成struct字段的赋值列表，如 []string{"name = 'a'", "age = 1"}
+func StructAssignments(data interface{}, fields []string) []string {
+	assignments, args := structAssignmentArgs(data, fields)
+	for i := range assignments {
+		assignments[i] = inlineArgs(resolveIdents(assignments[i], false, nil), args[i:i+1], nil)
+	}
+	return assignments
+}
+
+// 与StructAssignments相同，但值使用参数标记，每个赋值对应一个参数
+func structAssignmentArgs(data interface{}, fields []string) ([]string, []interface{}) {
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		log.Panic("sqlol: data must be struct.")
+	}
+	var assignments []string
+	var args []interface{}
+	for _, fieldName := range fields {
+		field := structField(value, fieldName)
+		if !field.IsValid() {
+			log.Panic("sqlol: no field '" + fieldName + "' in struct")
+		}
+		assignments = append(assignments, markIdent(CamelToSnake(fieldName))+" = "+argMarker)
+		args = append(args, field.Interface())
+	}
+	return assignments, args
 }
 
 func structField(strct reflect.Value, fieldName string) reflect.Value {
@@ -275,3 +591,22 @@ func structField(strct reflect.Value, fieldName string) reflect.Value {
 	}
 	return strct
 }
+
+// 查找snake形式为col的导出字段，如 id => Id，找不到时返回无效的Value
+func structKeyField(strct reflect.Value, col string) reflect.Value {
+	for _, name := range structExportedFields(strct.Type()) {
+		if CamelToSnake(name) == col {
+			return structField(strct, name)
+		}
+	}
+	return reflect.Value{}
+}
+
+func containsString(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
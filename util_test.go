@@ -1,8 +1,14 @@
 package sqlol
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestStructExportedFields(t *testing.T) {
@@ -65,3 +71,199 @@ func TestStructExportedFields(t *testing.T) {
 		})
 	}
 }
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		ident string
+		want  string
+	}{
+		{"status", `"status"`},
+		{"o.status", `"o"."status"`},
+		{"a.user.name", `"a"."user"."name"`},
+		{"o.*", `"o".*`},
+		{`we"ird`, `"we""ird"`},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.ident); got != tt.want {
+			t.Errorf("Quote(%v) = %v, want %v", tt.ident, got, tt.want)
+		}
+	}
+}
+
+func TestArrayString(t *testing.T) {
+	tests := []struct {
+		values interface{}
+		want   string
+	}{
+		{[]string{"a", "b"}, `'{"a","b"}'`},
+		{[]string{"it's", `say "hi"`, "a,b", "{c}", `back\slash`},
+			`'{"it''s","say \"hi\"","a,b","{c}","back\\slash"}'`},
+		{[]int{1, 2}, `'{1,2}'`},
+		{[]interface{}{"a", nil}, `'{"a",NULL}'`},
+		{[][]int{{1, 2}, {3, 4}}, `'{{1,2},{3,4}}'`},
+		{[]string{}, `'{}'`},
+	}
+	for _, tt := range tests {
+		if got := ArrayString(tt.values); got != tt.want {
+			t.Errorf("ArrayString(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+type testDecimal struct {
+	value int64
+	exp   int
+}
+
+func (d testDecimal) String() string {
+	s := strconv.FormatInt(d.value, 10)
+	return s[:len(s)-d.exp] + "." + s[len(s)-d.exp:]
+}
+
+func (d testDecimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+type testVersion struct {
+	Major, Minor int
+}
+
+func (v testVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+func TestToStringNumeric(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{Numeric("123.45"), "123.45"},
+		{Numeric("-0.1"), "-0.1"},
+		{testDecimal{12345, 2}, "123.45"},
+		{&testDecimal{10000000000000001, 2}, "100000000000000.01"},
+	}
+	for _, tt := range tests {
+		if got := ToString(tt.value); got != tt.want {
+			t.Errorf("ToString(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	// 只实现了fmt.Stringer的结构体不按数值生成
+	if got, want := ToString(testVersion{1, 2}), `'{"Major":1,"Minor":2}'`; got != want {
+		t.Errorf("ToString(testVersion) = %v, want %v", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("ToString() should panic on invalid numeric")
+		}
+	}()
+	ToString(Numeric("1; DROP TABLE a"))
+}
+
+func TestToStringNull(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"string", sql.NullString{String: "123", Valid: true}, "'123'"},
+		{"string quote", sql.NullString{String: "a'b", Valid: true}, "'a''b'"},
+		{"string invalid", sql.NullString{String: "a"}, "NULL"},
+		{"int64", sql.NullInt64{Int64: 42, Valid: true}, "42"},
+		{"int64 invalid", sql.NullInt64{}, "NULL"},
+		{"int32", sql.NullInt32{Int32: -7, Valid: true}, "-7"},
+		{"int32 invalid", sql.NullInt32{}, "NULL"},
+		{"float64", sql.NullFloat64{Float64: 1.5, Valid: true}, "1.5"},
+		{"float64 invalid", sql.NullFloat64{}, "NULL"},
+		{"bool", sql.NullBool{Bool: false, Valid: true}, "false"},
+		{"bool invalid", sql.NullBool{}, "NULL"},
+		{"time", sql.NullTime{Time: tm, Valid: true}, "'2020-01-02T03:04:05Z'"},
+		{"time invalid", sql.NullTime{Time: tm}, "NULL"},
+		{"pointer", &sql.NullString{String: "1", Valid: true}, "'1'"},
+		{"nil pointer", (*sql.NullString)(nil), "NULL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToString(tt.value); got != tt.want {
+				t.Errorf("ToString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToStringArray(t *testing.T) {
+	if got, want := ToString(AsArray([]string{"a,b", `c"d`})), `'{"a,b","c\"d"}'`; got != want {
+		t.Errorf("ToString() = %v, want %v", got, want)
+	}
+	if got, want := ToString([]string{"a,b"}), `'["a,b"]'`; got != want {
+		t.Errorf("ToString() = %v, want %v", got, want)
+	}
+	if got, want := ToString(AsArray(nil)), "NULL"; got != want {
+		t.Errorf("ToString() = %v, want %v", got, want)
+	}
+	if got, want := ToString(AsArray([]int{1})), "'{1}'"; got != want {
+		t.Errorf("ToString() = %v, want %v", got, want)
+	}
+}
+
+type testUUID [2]byte
+
+func TestRegisterType(t *testing.T) {
+	typ := reflect.TypeOf(testUUID{})
+	RegisterType(typ, func(v interface{}) string {
+		u := v.(testUUID)
+		return String(fmt.Sprintf("%02x%02x", u[0], u[1])) + "::uuid"
+	})
+	defer RegisterType(typ, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, want := ToString(testUUID{0xab, 0x01}), "'ab01'::uuid"; got != want {
+				t.Errorf("ToString() = %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := ToString(&testUUID{0xab, 0x01}), "'ab01'::uuid"; got != want {
+		t.Errorf("ToString() = %v, want %v", got, want)
+	}
+	RegisterType(typ, nil)
+	if got, want := ToString(testUUID{1, 2}), "'[1,2]'"; got != want {
+		t.Errorf("ToString() = %v, want %v", got, want)
+	}
+}
+
+type testZeroer struct {
+	Value int
+}
+
+func (testZeroer) IsZero() bool {
+	return true
+}
+
+func TestIsEmpty(t *testing.T) {
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var nilTime *time.Time
+	tests := []struct {
+		name  string
+		value interface{}
+		want  bool
+	}{
+		{"zero time", time.Time{}, true},
+		{"zero time in location", time.Time{}.In(time.FixedZone("CST", 8*3600)), true},
+		{"nil time pointer", nilTime, true},
+		{"time", tm, false},
+		{"time pointer", &tm, false},
+		// 其它实现了IsZero的类型仍按字段是否为零值判断
+		{"other IsZero", testZeroer{Value: 1}, false},
+		{"other IsZero zero value", testZeroer{}, true},
+	}
+	for _, tt := range tests {
+		if got := isEmpty(tt.value); got != tt.want {
+			t.Errorf("isEmpty(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
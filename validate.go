@@ -0,0 +1,137 @@
+package sqlol
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type clause struct {
+	name          string
+	set           bool
+	manipulations []string
+}
+
+// 在Build之前检查builder，返回第一个问题，没有问题时返回nil：
+// 结构问题(缺少操作类型、表名、插入值或字段，更新值，删除条件等)返回与BuildE相同的*BuildError，
+// 可通过errors.Is判断，如 errors.Is(err, ErrNoTable)；
+// 其次检查是否设置了与当前操作不匹配的子句，如SELECT设置了Values、DELETE设置了GroupBy，
+// 这些子句在Build时会被忽略，容易掩盖错误
+func (b *Builder) Validate() error {
+	if errs := b.structuralErrors(); len(errs) > 0 {
+		return &BuildError{Manipulation: b.manipulation, Err: errs[0]}
+	}
+	if errs := b.clauseErrors(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+var (
+	errGroupNotEnded  = errors.New("sqlol: condition group is not ended")
+	errJoinWithoutOn  = errors.New("sqlol: JOIN requires an ON condition except CROSS and NATURAL JOIN")
+	errLockWaitNoLock = errors.New("sqlol: SKIP LOCKED and NOWAIT require ForUpdate or ForShare")
+)
+
+// 返回导致无法生成sql的结构问题，Build时遇到第一个问题即失败
+func (b *Builder) structuralErrors() []error {
+	var errs []error
+	if b.manipulation == "" {
+		errs = append(errs, ErrNoManipulation)
+	}
+	if b.table == "" {
+		errs = append(errs, ErrNoTable)
+	}
+	switch b.manipulation {
+	case manipulationInsert:
+		if b.values == nil || (b.emptyValues() && !b.allowEmptyInsert) {
+			errs = append(errs, ErrNoValues)
+		} else if b.missingInsertCols() {
+			errs = append(errs, ErrNoCols)
+		}
+	case manipulationUpdate:
+		if len(b.updates) == 0 && b.updateStruct == nil {
+			errs = append(errs, ErrNoValues)
+		}
+	case manipulationDelete:
+		if len(b.ConditionBuilder.wheres) == 0 {
+			errs = append(errs, ErrNoDeleteCondition)
+		}
+	}
+	if len(b.ConditionBuilder.groups) > 0 || len(b.HavingBuilder.groups) > 0 {
+		errs = append(errs, errGroupNotEnded)
+	}
+	if b.lockWait != "" && !b.isForUpdate && !b.isForShare {
+		errs = append(errs, errLockWaitNoLock)
+	}
+	for _, j := range b.join {
+		if strings.TrimSpace(j.on) == "" && !j.withoutOn() {
+			errs = append(errs, errJoinWithoutOn)
+			break
+		}
+	}
+	return errs
+}
+
+// 插入struct时既没有指定Cols，也无法从struct推导出插入字段
+func (b *Builder) missingInsertCols() bool {
+	if _, ok := b.values.(*Builder); ok || len(b.cols) > 0 || b.emptyValues() {
+		return false
+	}
+	t := reflect.TypeOf(b.values)
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && len(b.insertCols()) == 0
+}
+
+// 返回所有与当前操作不匹配的子句错误
+func (b *Builder) clauseErrors() []error {
+	if b.manipulation == "" {
+		return nil
+	}
+	var errs []error
+	for _, c := range b.clauses() {
+		if c.set && !containsString(c.manipulations, b.manipulation) {
+			errs = append(errs, fmt.Errorf("sqlol: %s is not allowed in %s", c.name, b.manipulation))
+		}
+	}
+	if _, ok := b.dialect().(PercentDialect); b.limitPercent > 0 && !ok {
+		errs = append(errs, errPercentDialect)
+	}
+	if len(b.returning) > 0 {
+		if _, err := b.dialect().Returning(b.returning); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (b *Builder) clauses() []clause {
+	s, i, u, d := manipulationSelect, manipulationInsert, manipulationUpdate, manipulationDelete
+	return []clause{
+		{"Fields", len(b.fields) > 0, []string{s}},
+		{"Distinct", b.distinct || len(b.distinctOn) > 0, []string{s}},
+		{"Join", len(b.join) > 0, []string{s}},
+		{"GroupBy", len(b.groupBy) > 0, []string{s}},
+		{"Having", len(b.HavingBuilder.wheres) > 0, []string{s}},
+		{"ForUpdate", b.isForUpdate, []string{s}},
+		{"ForShare", b.isForShare, []string{s}},
+		{"OrderBy", len(b.orderBy) > 0, []string{s, u, d}},
+		{"Limit", b.limit > 0, []string{s, u, d}},
+		{"LimitPercent", b.limitPercent > 0, []string{s}},
+		{"Offset", b.offset > 0, []string{s, u, d}},
+		{"Where", len(b.ConditionBuilder.wheres) > 0, []string{s, u, d}},
+		{"Cols", len(b.cols) > 0, []string{i, u}},
+		{"Values", b.values != nil, []string{i}},
+		{"OnConflict", b.onConflict != "" || b.conflictUpdate != nil, []string{i}},
+		{"Set", len(b.updates) > 0, []string{u}},
+		{"SetStruct", b.updateStruct != nil, []string{u}},
+		// tip: SELECT不支持RETURNING
+		{"Returning", len(b.returning) > 0, []string{i, u, d}},
+	}
+}
@@ -0,0 +1,133 @@
+package sqlol
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBuilder_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+		wantErr string
+	}{
+		{
+			name:    "select",
+			builder: NewBuilder().Select("a.user").Fields("id").GroupBy("id").Equal("id", 1),
+		},
+		{
+			name:    "values on select",
+			builder: NewBuilder().Select("a.user").Values(User{}),
+			wantErr: "sqlol: Values is not allowed in SELECT",
+		},
+		{
+			name:    "fields on insert",
+			builder: NewBuilder().Insert("a.user").Values(User{}).Fields("id"),
+			wantErr: "sqlol: Fields is not allowed in INSERT",
+		},
+		{
+			name:    "group by on delete",
+			builder: NewBuilder().Delete("a.user").Equal("id", 1).GroupBy("id"),
+			wantErr: "sqlol: GroupBy is not allowed in DELETE",
+		},
+		{
+			name:    "where on insert",
+			builder: NewBuilder().Insert("a.user").Values(User{}).Equal("id", 1),
+			wantErr: "sqlol: Where is not allowed in INSERT",
+		},
+		{
+			name:    "set on select",
+			builder: NewBuilder().Select("a.user").Set("age = 1"),
+			wantErr: "sqlol: Set is not allowed in SELECT",
+		},
+		{
+			name:    "for update on update",
+			builder: NewBuilder().Update("a.user").Set("age = 1").ForUpdate(),
+			wantErr: "sqlol: ForUpdate is not allowed in UPDATE",
+		},
+		{
+			name:    "returning on select",
+			builder: NewBuilder().Select("a.user").Returning("id"),
+			wantErr: "sqlol: Returning is not allowed in SELECT",
+		},
+		{
+			name:    "returning on insert",
+			builder: NewBuilder().Insert("a.user").Values(User{}).Returning("id"),
+		},
+		{
+			name:    "returning on update",
+			builder: NewBuilder().Update("a.user").Set("age = 1").Returning("id"),
+		},
+		{
+			name:    "returning on delete",
+			builder: NewBuilder().Delete("a.user").Equal("id", 1).Returning("id"),
+		},
+		{
+			name:    "returning on mysql",
+			builder: NewBuilder().Dialect(MySQL).Insert("a.user").Values(User{}).Returning("id"),
+			wantErr: errReturningDialect.Error(),
+		},
+		{
+			name:    "limit percent on postgres",
+			builder: NewBuilder().Select("a.user").LimitPercent(10),
+			wantErr: errPercentDialect.Error(),
+		},
+		{
+			name:    "limit percent on percent dialect",
+			builder: NewBuilder().Dialect(fetchDialect{}).Select("a.user").LimitPercent(10),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.builder.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuilder_ValidateStructure(t *testing.T) {
+	type Empty struct{}
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    error
+	}{
+		{"no manipulation", NewBuilder(), ErrNoManipulation},
+		{"no table", NewBuilder().Select(""), ErrNoTable},
+		{"insert without values", NewBuilder().Insert("a.user"), ErrNoValues},
+		{"insert empty values", NewBuilder().Insert("a.user").Values([]User{}), ErrNoValues},
+		{"insert without cols", NewBuilder().Insert("a.user").Values(Empty{}), ErrNoCols},
+		{"update without values", NewBuilder().Update("a.user").Equal("id", 1), ErrNoValues},
+		{"delete without where", NewBuilder().Delete("a.user"), ErrNoDeleteCondition},
+		{"group not ended", NewBuilder().Select("a.user").BeginGroup(), errGroupNotEnded},
+		{"skip locked without lock", NewBuilder().Select("a.job").SkipLocked(), errLockWaitNoLock},
+		{"join without on", NewBuilder().Select("a.user").LeftJoin("a.order", "o", ""), errJoinWithoutOn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.builder.Validate()
+			var buildErr *BuildError
+			if !errors.Is(err, tt.want) || !errors.As(err, &buildErr) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+			if _, buildE := tt.builder.BuildE(); !errors.Is(buildE, tt.want) {
+				t.Errorf("BuildE() error = %v, want %v", buildE, tt.want)
+			}
+		})
+	}
+	if err := NewBuilder().Insert("a.user").Values([]User{}).AllowEmptyInsert().Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+	if err := NewBuilder().Select("a.user").CrossJoin("a.tag", "t").
+		Join("NATURAL", "a.profile", "", "").Validate(); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}